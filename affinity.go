@@ -0,0 +1,157 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+// AffinityTasker is a Tasker that, with Options.Affinity, is
+// executed by the worker its key maps to, so that Taskers sharing
+// a key run on the same worker, e.g. to keep a per-worker cache warm.
+type AffinityTasker interface {
+	Tasker
+	AffinityKey() uint64
+}
+
+// affinity holds the queues of workers for AffinityTaskers.
+type affinity struct {
+	queues []chan indexedTask
+	steal  bool
+}
+
+// newAffinity returns the queues of n workers,
+// nil if opts.Affinity is not set.
+func newAffinity(opts Options, n int) *affinity {
+	if !opts.Affinity {
+		return nil
+	}
+	a := &affinity{queues: make([]chan indexedTask, n), steal: opts.WorkStealing}
+	for i := range a.queues {
+		a.queues[i] = make(chan indexedTask, opts.queueDepth(n))
+	}
+	return a
+}
+
+// queueFor returns the queue t must be sent to: the one of its
+// worker if t is an AffinityTasker, jobsQueue otherwise. With steal
+// set spill is jobsQueue when the queue of the worker is full, so
+// that idle workers take t, nil otherwise.
+func (a *affinity) queueFor(t Tasker, jobsQueue chan<- indexedTask) (q, spill chan<- indexedTask) {
+	k, ok := t.(AffinityTasker)
+	if a == nil || !ok {
+		return jobsQueue, nil
+	}
+	own := a.queues[k.AffinityKey()%uint64(len(a.queues))]
+	if a.steal && len(own) == cap(own) {
+		return own, jobsQueue
+	}
+	return own, nil
+}
+
+// queued returns the number of Taskers in the queues of workers.
+func (a *affinity) queued() int {
+	if a == nil {
+		return 0
+	}
+	var n int
+	for _, q := range a.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// close closes the queues of workers.
+func (a *affinity) close() {
+	if a == nil {
+		return
+	}
+	for _, q := range a.queues {
+		close(q)
+	}
+}
+
+// workerQueue is where a worker takes Taskers from: its own
+// queue of AffinityTaskers, if any, and the shared one.
+// With steal set it takes Taskers queued for other workers
+// when both are empty before waiting, and once all are closed.
+type workerQueue struct {
+	own, shared <-chan indexedTask
+	others      []chan indexedTask
+	steal       bool
+}
+
+// newWorkerQueue returns the queue of worker id.
+func newWorkerQueue(id int, jobsQueue <-chan indexedTask, a *affinity) *workerQueue {
+	q := &workerQueue{shared: jobsQueue}
+	if a == nil {
+		return q
+	}
+	q.own = a.queues[id]
+	// Steal starting from the next worker,
+	// so that workers do not all rob the first.
+	q.others = append(append(q.others, a.queues[id+1:]...), a.queues[:id]...)
+	q.steal = a.steal
+	return q
+}
+
+// poll returns a Tasker without blocking, preferring the own queue.
+// Closed queues are forgotten.
+func (q *workerQueue) poll() (indexedTask, bool) {
+	if q.own != nil {
+		select {
+		case j, ok := <-q.own:
+			if ok {
+				return j, true
+			}
+			q.own = nil
+		default:
+		}
+	}
+	if q.shared != nil {
+		select {
+		case j, ok := <-q.shared:
+			if ok {
+				return j, true
+			}
+			q.shared = nil
+		default:
+		}
+	}
+	return q.stolen()
+}
+
+// stolen returns a Tasker queued for another worker, if steal is set.
+func (q *workerQueue) stolen() (indexedTask, bool) {
+	if !q.steal {
+		return indexedTask{}, false
+	}
+	for _, o := range q.others {
+		select {
+		case j, ok := <-o:
+			if ok {
+				return j, true
+			}
+		default:
+		}
+	}
+	return indexedTask{}, false
+}
+
+// closed reports whether the queues of the worker are closed.
+func (q *workerQueue) closed() bool {
+	return q.own == nil && q.shared == nil
+}
+
+// waiting reports whether Taskers wait in the queues of the worker.
+func (q *workerQueue) waiting() bool {
+	if len(q.own) > 0 || len(q.shared) > 0 {
+		return true
+	}
+	if q.steal {
+		for _, o := range q.others {
+			if len(o) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
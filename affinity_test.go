@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+// keyed is an AffinityTasker sleeping for d.
+type keyed struct {
+	key uint64
+	d   time.Duration
+}
+
+func (k *keyed) Execute() { time.Sleep(k.d) }
+
+func (k *keyed) AffinityKey() uint64 { return k.key }
+
+func TestRunWith_affinity(t *testing.T) {
+	const n = 4
+	var jobs []Tasker
+	for i := 0; i < 40; i++ {
+		jobs = append(jobs, &keyed{key: uint64(i % 7)})
+		if i%3 == 0 {
+			jobs = append(jobs, &dummy{})
+		}
+	}
+	s, err := RunWithStats(jobs, Options{Workers: n, Affinity: true, QueueDepth: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ts := range s.Tasks {
+		if !ts.Executed {
+			t.Fatal("task not executed:", i)
+		}
+		k, ok := jobs[i].(*keyed)
+		if !ok {
+			continue
+		}
+		if w := int(k.key % n); ts.Worker != w {
+			t.Fatalf("task %d with key %d executed by worker %d, expected %d", i, k.key, ts.Worker, w)
+		}
+	}
+}
+
+func TestRunWith_workStealing(t *testing.T) {
+	var jobs []Tasker
+	for i := 0; i < 20; i++ {
+		jobs = append(jobs, &keyed{d: 5 * time.Millisecond})
+	}
+	s, err := RunWithStats(jobs, Options{Workers: 4, Affinity: true, WorkStealing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stolen int
+	for i, ts := range s.Tasks {
+		if !ts.Executed {
+			t.Fatal("task not executed:", i)
+		}
+		if ts.Worker != 0 {
+			stolen++
+		}
+	}
+	if stolen == 0 {
+		t.Fatal("expected idle workers to execute tasks of worker 0")
+	}
+	// Without stealing worker 0 executes all of them.
+	s, err = RunWithStats(jobs, Options{Workers: 4, Affinity: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ts := range s.Tasks {
+		if ts.Worker != 0 {
+			t.Fatalf("task %d executed by worker %d", i, ts.Worker)
+		}
+	}
+}
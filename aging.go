@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"container/heap"
+	"time"
+)
+
+// NewPriorityPool is like NewPool but pending Taskers are dispatched
+// in order of decreasing priority, see PriorityTasker, rather than in
+// submission order. To prevent starvation of low priority Taskers
+// under a steady load of higher priority ones, a pending Tasker gains
+// aging priority points for every second it waits. Zero or less
+// means no aging.
+func NewPriorityPool(workers int, aging float64) *Pool {
+	p := newPool(workers)
+	if aging < 0 {
+		aging = 0
+	}
+	// Workers take Taskers only once they are
+	// ready, pending ones wait in the heap.
+	p.out = make(chan poolTask)
+	go dispatch(p.queue, p.out, aging)
+	p.start()
+	return p
+}
+
+// dispatch sends Taskers received from in to out, highest effective
+// priority first. out is closed once in is closed and drained.
+func dispatch(in <-chan poolTask, out chan<- poolTask, aging float64) {
+	defer close(out)
+	start := time.Now()
+	var pending agedTasks
+	for in != nil || pending.Len() > 0 {
+		var send chan<- poolTask
+		var next poolTask
+		if pending.Len() > 0 {
+			send = out
+			next = pending.tasks[0].t
+		}
+		select {
+		case t, ok := <-in:
+			if !ok {
+				in = nil
+				break
+			}
+			// All pending Taskers age at the same rate, so
+			// priority minus the aging accumulated before the
+			// submission orders them at any time.
+			key := float64(priority(t.Tasker)) - aging*time.Since(start).Seconds()
+			heap.Push(&pending, agedTask{t, key, pending.seq})
+			pending.seq++
+		case send <- next:
+			heap.Pop(&pending)
+		}
+	}
+}
+
+// agedTask is a pending Tasker, key is its effective priority
+// and seq breaks ties in submission order.
+type agedTask struct {
+	t   poolTask
+	key float64
+	seq int
+}
+
+// agedTasks is a max heap of pending Taskers.
+type agedTasks struct {
+	tasks []agedTask
+	seq   int
+}
+
+func (a *agedTasks) Len() int { return len(a.tasks) }
+
+func (a *agedTasks) Less(i, j int) bool {
+	if a.tasks[i].key != a.tasks[j].key {
+		return a.tasks[i].key > a.tasks[j].key
+	}
+	return a.tasks[i].seq < a.tasks[j].seq
+}
+
+func (a *agedTasks) Swap(i, j int) { a.tasks[i], a.tasks[j] = a.tasks[j], a.tasks[i] }
+
+func (a *agedTasks) Push(x any) { a.tasks = append(a.tasks, x.(agedTask)) }
+
+func (a *agedTasks) Pop() any {
+	t := a.tasks[len(a.tasks)-1]
+	a.tasks = a.tasks[:len(a.tasks)-1]
+	return t
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// urgent is a sleeper with a high priority.
+type urgent struct {
+	sleeper
+}
+
+func (u *urgent) Priority() int { return 10 }
+
+// drain reads results until it's closed.
+func drain(results chan PoolResult) {
+	for range results {
+	}
+}
+
+func TestPriorityPool(t *testing.T) {
+	p := NewPriorityPool(1, 0)
+	results := make(chan PoolResult, 10)
+	s := stuck{make(chan struct{})}
+	if err := p.SubmitAsync(s, nil, results); err != nil {
+		t.Fatal(err)
+	}
+	for p.Active() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	var order []int
+	for _, prio := range []int{1, 3, 0, 4, 2} {
+		task := &prioritized{weighted{recorder{i: prio, order: &order}, 0}, prio}
+		if err := p.SubmitAsync(task, nil, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let the dispatcher receive all Taskers.
+	time.Sleep(10 * time.Millisecond)
+	close(s.release)
+	p.Close()
+	if expected := []int{4, 3, 2, 1, 0}; !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected order %v, got %v", expected, order)
+	}
+}
+
+func TestPriorityPool_aging(t *testing.T) {
+	for _, c := range []struct {
+		aging  float64
+		starve bool
+	}{{0, true}, {1e4, false}} {
+		p := NewPriorityPool(1, c.aging)
+		results := make(chan PoolResult, 10)
+		go drain(results)
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		// Submit high priority Taskers faster than
+		// the worker executes them.
+		go func() {
+			defer close(stopped)
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				p.SubmitAsync(&urgent{sleeper{2 * time.Millisecond}}, nil, results)
+				p.SubmitAsync(&urgent{sleeper{2 * time.Millisecond}}, nil, results)
+				time.Sleep(time.Millisecond)
+			}
+		}()
+		time.Sleep(5 * time.Millisecond)
+		low := make(chan struct{})
+		p.SubmitAsync(TaskFunc(func() { close(low) }), nil, results)
+		var ran bool
+		select {
+		case <-low:
+			ran = true
+		case <-time.After(100 * time.Millisecond):
+		}
+		close(stop)
+		<-stopped
+		p.Close()
+		close(results)
+		if ran == c.starve {
+			t.Fatalf("aging %g: low priority task executed: %t", c.aging, ran)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"time"
+)
+
+// AutoWorkers runs sample with a few numbers of workers, up to the
+// package default, and returns the one that took less, to be used as
+// Options.Workers for Taskers of the same kind. It detects machines
+// where using all the cores, e.g. hyperthreads, does not pay off.
+// Every Tasker in sample is executed once per candidate, so it must
+// be repeatable. An empty sample returns the package default.
+func AutoWorkers(sample []Tasker) int {
+	best := workers()
+	if len(sample) == 0 {
+		return best
+	}
+	var bestTime time.Duration
+	for i, n := range workersCandidates(best) {
+		stats, _ := RunWithStats(sample, Options{Workers: n, Signals: []os.Signal{}})
+		if i == 0 || stats.Elapsed < bestTime {
+			best, bestTime = n, stats.Elapsed
+		}
+	}
+	return best
+}
+
+// workersCandidates returns numbers of workers to
+// try, halving most down to 1.
+func workersCandidates(most int) []int {
+	var candidates []int
+	for n := most; n > 1; n /= 2 {
+		candidates = append(candidates, n)
+	}
+	return append(candidates, 1)
+}
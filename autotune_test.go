@@ -0,0 +1,37 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoWorkers(t *testing.T) {
+	withWorkers(t, 4)
+	if n := AutoWorkers(nil); n != 4 {
+		t.Fatalf("expected default of 4 workers, got %d", n)
+	}
+	var sample []Tasker
+	for i := 0; i < 1e5; i += 1e4 {
+		sample = append(sample, &primeInterval{start: i, stop: i + 1e4 - 1})
+	}
+	if n := AutoWorkers(sample); n < 1 || n > 4 {
+		t.Fatalf("expected 1 to 4 workers, got %d", n)
+	}
+}
+
+func TestWorkersCandidates(t *testing.T) {
+	for most, expected := range map[int][]int{
+		1: {1},
+		2: {2, 1},
+		6: {6, 3, 1},
+		8: {8, 4, 2, 1},
+	} {
+		if c := workersCandidates(most); !reflect.DeepEqual(c, expected) {
+			t.Fatalf("%d: expected %v, got %v", most, expected, c)
+		}
+	}
+}
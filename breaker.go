@@ -0,0 +1,115 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen says that a Tasker was not executed
+// because its CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets Taskers through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails Taskers with ErrCircuitOpen.
+	CircuitOpen
+	// CircuitHalfOpen has let a trial Tasker through, others fail
+	// with ErrCircuitOpen until its outcome closes or opens it.
+	CircuitHalfOpen
+)
+
+// CircuitBreaker stops executing Taskers once they keep failing,
+// e.g. because the dependency they call is down, see
+// Options.CircuitBreaker. It's safe for concurrent use and can be
+// shared among runs calling the same dependency.
+type CircuitBreaker struct {
+	failures int
+	cooldown time.Duration
+	mu       sync.Mutex
+	state    CircuitState
+	// failed counts consecutive failures while closed.
+	failed   int
+	openedAt time.Time
+}
+
+// NewCircuitBreaker returns a closed CircuitBreaker that opens after
+// failures consecutive failed Taskers, at least 1, and lets a trial
+// Tasker through once cooldown elapsed: if it succeeds the breaker
+// closes, otherwise it opens for another cooldown.
+func NewCircuitBreaker(failures int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{failures: max(failures, 1), cooldown: max(cooldown, 0)}
+}
+
+// State returns the state of cb, open until a trial Tasker is let
+// through even if cooldown elapsed.
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// allow reports whether a Tasker can be executed,
+// trial tells whether it's the one of a half-open cb.
+func (cb *CircuitBreaker) allow() (ok, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.state {
+	case CircuitClosed:
+		return true, false
+	case CircuitOpen:
+		if time.Since(cb.openedAt) >= cb.cooldown {
+			cb.state = CircuitHalfOpen
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// record updates cb with the outcome of an allowed Tasker. Outcomes
+// of Taskers started before cb opened do not change it once open.
+func (cb *CircuitBreaker) record(failed, trial bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch {
+	case trial && failed:
+		cb.state = CircuitOpen
+		cb.openedAt = time.Now()
+	case trial:
+		cb.state = CircuitClosed
+		cb.failed = 0
+	case cb.state != CircuitClosed:
+	case !failed:
+		cb.failed = 0
+	default:
+		cb.failed++
+		if cb.failed >= cb.failures {
+			cb.state = CircuitOpen
+			cb.openedAt = time.Now()
+		}
+	}
+}
+
+// execute executes j as executeTimeout does if cb, when not nil,
+// lets it through, recording its outcome.
+func (cb *CircuitBreaker) execute(ctx context.Context, j indexedTask, d time.Duration) error {
+	if cb == nil {
+		return executeTimeout(ctx, j, d)
+	}
+	ok, trial := cb.allow()
+	if !ok {
+		return ErrCircuitOpen
+	}
+	err := executeTimeout(ctx, j, d)
+	cb.record(err != nil, trial)
+	return err
+}
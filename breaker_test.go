@@ -0,0 +1,108 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// downstream fails while down is set, counting calls.
+type downstream struct {
+	down  atomic.Bool
+	calls int32
+}
+
+func (d *downstream) Execute() error {
+	atomic.AddInt32(&d.calls, 1)
+	if d.down.Load() {
+		return errOdd
+	}
+	return nil
+}
+
+func TestRunErrWith_circuitBreaker(t *testing.T) {
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			d := &downstream{}
+			d.down.Store(true)
+			jobs := make([]ErrTasker, 100)
+			for i := range jobs {
+				jobs[i] = d
+			}
+			cb := NewCircuitBreaker(3, time.Hour)
+			errs := RunErrWith(jobs, Options{Workers: n, CircuitBreaker: cb})
+			var open int
+			for _, err := range errs {
+				if errors.Is(err, ErrCircuitOpen) {
+					open++
+				} else if err != errOdd {
+					t.Fatal("expected errOdd or ErrCircuitOpen, got", err)
+				}
+			}
+			// Taskers started before the breaker opened are executed.
+			if calls := atomic.LoadInt32(&d.calls); calls < 3 || int(calls) > 3+n {
+				t.Fatalf("expected 3 to %d calls, got %d", 3+n, calls)
+			}
+			if open != 100-int(d.calls) {
+				t.Fatalf("expected %d tasks failed by the breaker, got %d", 100-d.calls, open)
+			}
+			if s := cb.State(); s != CircuitOpen {
+				t.Fatal("expected an open breaker, got", s)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker_halfOpen(t *testing.T) {
+	d := &downstream{}
+	d.down.Store(true)
+	cb := NewCircuitBreaker(1, 10*time.Millisecond)
+	opts := Options{Workers: 1, CircuitBreaker: cb}
+	errs := RunErrWith([]ErrTasker{d, d}, opts)
+	if errs[0] != errOdd || !errors.Is(errs[1], ErrCircuitOpen) {
+		t.Fatal("expected errOdd and ErrCircuitOpen, got", errs)
+	}
+	time.Sleep(10 * time.Millisecond)
+	// The failed trial opens the breaker again.
+	errs = RunErrWith([]ErrTasker{d, d}, opts)
+	if errs[0] != errOdd || !errors.Is(errs[1], ErrCircuitOpen) {
+		t.Fatal("expected errOdd and ErrCircuitOpen, got", errs)
+	}
+	time.Sleep(10 * time.Millisecond)
+	d.down.Store(false)
+	errs = RunErrWith([]ErrTasker{d, d}, opts)
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatal("expected the trial to close the breaker, got", errs)
+	}
+	if s := cb.State(); s != CircuitClosed {
+		t.Fatal("expected a closed breaker, got", s)
+	}
+}
+
+func TestCircuitBreaker_retries(t *testing.T) {
+	d := &downstream{}
+	d.down.Store(true)
+	cb := NewCircuitBreaker(2, time.Hour)
+	errs := RunErrWith([]ErrTasker{d, d}, Options{
+		Workers:        1,
+		MaxRetries:     5,
+		RetryQueue:     10,
+		CircuitBreaker: cb,
+	})
+	// The first attempt and the first retry open the breaker,
+	// other attempts are not made.
+	if calls := atomic.LoadInt32(&d.calls); calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("expected ErrCircuitOpen, got", err)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrBudgetExceeded says that a batch has been aborted because it
+// consumed all of its CPU time budget.
+var ErrBudgetExceeded = errors.New("CPU time budget exceeded, not all tasks have been completed")
+
+// RunCPUBudget executes jobs like Run but stops starting new Taskers
+// once they consumed budget of CPU time, returning ErrBudgetExceeded.
+// Running Taskers are not interrupted so the budget can be overrun by
+// their residual cost, Taskers not started are canceled as in Run.
+// On Linux workers are locked to their threads and CPU time is the one
+// of threads executing Taskers: goroutines started by Taskers are not
+// accounted. Elsewhere CPU time is measured for the whole process, so
+// that concurrent work outside the batch consumes the budget too, and
+// wall-clock time is used where it is not available.
+func RunCPUBudget(jobs []Tasker, budget time.Duration) error {
+	b := &cpuBudget{
+		shortCircuiter: newShortCircuiter(),
+		start:          cpuTime(),
+		budget:         budget,
+	}
+	wrapped := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		wrapped[i] = &budgetTask{Tasker: j, b: b}
+	}
+	if err := b.runWith(wrapped, Options{LockWorkerThreads: threadCPU}); err != nil {
+		return err
+	}
+	if b.tripped() {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+type cpuBudget struct {
+	shortCircuiter
+	start  time.Duration
+	budget time.Duration
+	// spent is the CPU time of executed Taskers
+	// in nanoseconds, where threadCPU is true.
+	spent atomic.Int64
+}
+
+// used returns the CPU time consumed by the batch so far.
+func (b *cpuBudget) used() time.Duration {
+	if threadCPU {
+		return time.Duration(b.spent.Load())
+	}
+	return cpuTime() - b.start
+}
+
+// budgetTask executes the wrapped Tasker while b has budget left.
+type budgetTask struct {
+	Tasker
+	b *cpuBudget
+}
+
+func (t *budgetTask) Execute() {
+	t.executeErr()
+}
+
+func (t *budgetTask) executeErr() error {
+	if t.b.stopped() {
+		return t.b.skip(t.Tasker)
+	}
+	if t.b.used() >= t.b.budget {
+		t.b.trip()
+		return t.b.skip(t.Tasker)
+	}
+	if threadCPU {
+		pre := threadCPUTime()
+		defer func() { t.b.spent.Add(int64(threadCPUTime() - pre)) }()
+	}
+	t.Tasker.Execute()
+	return nil
+}
+
+// OnCancel notifies the wrapped Tasker, if it's a Canceler.
+func (t *budgetTask) OnCancel() {
+	if c, ok := t.Tasker.(Canceler); ok {
+		c.OnCancel()
+	}
+}
+
+var processStart = time.Now()
+
+// wallTime returns wall-clock time elapsed since process start.
+func wallTime() time.Duration {
+	return time.Since(processStart)
+}
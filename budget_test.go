@@ -0,0 +1,53 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunCPUBudget(t *testing.T) {
+	tasks := make([]Tasker, 1e3)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	pre := cpuTime()
+	err := RunCPUBudget(tasks, 50*time.Millisecond)
+	if err != ErrBudgetExceeded {
+		t.Fatal("expected ErrBudgetExceeded, got", err)
+	}
+	if used := cpuTime() - pre; used > 500*time.Millisecond {
+		t.Errorf("batch used %s of CPU, too far from budget", used)
+	}
+	var executed int
+	for _, e := range tasks {
+		if e.(*dummy).done {
+			executed++
+		}
+	}
+	if executed == 0 || executed == len(tasks) {
+		t.Fatalf("expected a partial batch, %d tasks executed", executed)
+	}
+	if err := RunCPUBudget(tasks[:1], time.Hour); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunCPUBudget_cancel(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &cancelCloser{}
+	}
+	if err := RunCPUBudget(tasks, 0); err != ErrBudgetExceeded {
+		t.Fatal("expected ErrBudgetExceeded, got", err)
+	}
+	for i, e := range tasks {
+		c := e.(*cancelCloser)
+		if c.executed != 0 || c.canceled != 1 || c.cleaned != 1 {
+			t.Fatalf("task %d executed %d times, canceled %d times and cleaned up %d times", i, c.executed, c.canceled, c.cleaned)
+		}
+	}
+}
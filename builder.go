@@ -0,0 +1,121 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Builder builds Options with chained calls, e.g.
+//
+//	parallel.New().Workers(8).Timeout(30 * time.Second).FailFast().Run(jobs)
+//
+// Every method returns a copy, so that a Builder can be the base of
+// others. Options not covered by a method are set with With.
+type Builder struct {
+	opts Options
+}
+
+// New returns a Builder of the zero Options, the ones used by Run.
+func New() Builder {
+	return Builder{}
+}
+
+// Workers sets Options.Workers.
+func (b Builder) Workers(n int) Builder {
+	b.opts.Workers = n
+	return b
+}
+
+// QueueDepth sets Options.QueueDepth.
+func (b Builder) QueueDepth(n int) Builder {
+	b.opts.QueueDepth = n
+	return b
+}
+
+// Timeout sets Options.Timeout.
+func (b Builder) Timeout(d time.Duration) Builder {
+	b.opts.Timeout = d
+	return b
+}
+
+// TaskTimeout sets Options.TaskTimeout.
+func (b Builder) TaskTimeout(d time.Duration) Builder {
+	b.opts.TaskTimeout = d
+	return b
+}
+
+// Retries sets Options.MaxRetries and Options.RetryBackoff.
+func (b Builder) Retries(n int, backoff time.Duration) Builder {
+	b.opts.MaxRetries = n
+	b.opts.RetryBackoff = backoff
+	return b
+}
+
+// FailFast sets Options.FailFast.
+func (b Builder) FailFast() Builder {
+	b.opts.FailFast = true
+	return b
+}
+
+// MaxErrors sets Options.MaxErrors.
+func (b Builder) MaxErrors(n int) Builder {
+	b.opts.MaxErrors = n
+	return b
+}
+
+// Signals sets Options.Signals, no signals
+// disables signal handling.
+func (b Builder) Signals(sig ...os.Signal) Builder {
+	b.opts.Signals = append([]os.Signal{}, sig...)
+	return b
+}
+
+// Logger sets Options.Logger.
+func (b Builder) Logger(l Logger) Builder {
+	b.opts.Logger = l
+	return b
+}
+
+// ErrorMode sets Options.ErrorMode.
+func (b Builder) ErrorMode(m ErrorMode) Builder {
+	b.opts.ErrorMode = m
+	return b
+}
+
+// Strategy sets Options.Strategy.
+func (b Builder) Strategy(s Strategy) Builder {
+	b.opts.Strategy = s
+	return b
+}
+
+// With calls f to set Options directly.
+func (b Builder) With(f func(*Options)) Builder {
+	f(&b.opts)
+	return b
+}
+
+// Options returns the Options built so far.
+func (b Builder) Options() Options {
+	return b.opts
+}
+
+// Run executes jobs as RunWith does with the built Options.
+func (b Builder) Run(jobs []Tasker) error {
+	return RunWith(jobs, b.opts)
+}
+
+// RunContext is like Run but the run stops once ctx is done,
+// as RunContext does.
+func (b Builder) RunContext(ctx context.Context, jobs []Tasker) error {
+	return run(ctx, jobs, b.opts)
+}
+
+// RunErr executes jobs as RunErrWith does with the built Options.
+func (b Builder) RunErr(jobs []ErrTasker) []error {
+	return RunErrWith(jobs, b.opts)
+}
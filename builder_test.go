@@ -0,0 +1,59 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	base := New().Workers(3).Timeout(time.Second)
+	b := base.FailFast().
+		QueueDepth(5).
+		TaskTimeout(time.Millisecond).
+		Retries(2, time.Microsecond).
+		MaxErrors(4).
+		Signals().
+		ErrorMode(ErrFirst).
+		Strategy(StrategyChannels).
+		With(func(o *Options) { o.Dedup = true })
+	want := Options{
+		Workers:      3,
+		QueueDepth:   5,
+		Timeout:      time.Second,
+		TaskTimeout:  time.Millisecond,
+		MaxRetries:   2,
+		RetryBackoff: time.Microsecond,
+		FailFast:     true,
+		MaxErrors:    4,
+		Signals:      []os.Signal{},
+		ErrorMode:    ErrFirst,
+		Dedup:        true,
+	}
+	if got := b.Options(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %+v, got %+v", want, got)
+	}
+	// The base is not changed.
+	if base.Options().FailFast {
+		t.Fatal("base builder changed")
+	}
+	initTests()
+	if err := base.Run(testCases); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range testCases {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed")
+		}
+	}
+	errs := New().Workers(2).RunErr([]ErrTasker{&flaky{failures: 1}})
+	if !errors.Is(errs[0], errOdd) {
+		t.Fatal("expected errOdd, got", errs[0])
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "time"
+
+// Checkpoint persists the progress of a run, see Options.Checkpoint.
+// A run restarted with the same jobs, e.g. after the process was
+// killed, executes only Taskers not completed by previous runs.
+type Checkpoint interface {
+	// Load returns indices in jobs of the Taskers
+	// completed by previous runs.
+	Load() []int
+	// Save receives indices in jobs of the Taskers completed
+	// so far, loaded ones included, in increasing order.
+	Save(completed []int)
+}
+
+// checkpointer tracks Taskers completed with success for a Checkpoint.
+type checkpointer struct {
+	cp       Checkpoint
+	interval time.Duration
+	done     []bool
+	// dirty tells whether done changed since the last Save.
+	dirty bool
+	saved time.Time
+}
+
+// newCheckpointer returns a checkpointer of n jobs loading
+// completed ones, nil if opts.Checkpoint is nil.
+func newCheckpointer(opts Options, n int) *checkpointer {
+	if opts.Checkpoint == nil {
+		return nil
+	}
+	c := &checkpointer{
+		cp:       opts.Checkpoint,
+		interval: opts.CheckpointInterval,
+		done:     make([]bool, n),
+		saved:    time.Now(),
+	}
+	for _, i := range opts.Checkpoint.Load() {
+		// Jobs may have changed since the checkpoint.
+		if i >= 0 && i < n {
+			c.done[i] = true
+		}
+	}
+	return c
+}
+
+// skip returns tasks not completed by previous runs.
+func (c *checkpointer) skip(tasks []indexedTask) []indexedTask {
+	if c == nil {
+		return tasks
+	}
+	left := tasks[:0:0]
+	for _, t := range tasks {
+		if !c.done[t.index] {
+			left = append(left, t)
+		}
+	}
+	return left
+}
+
+// completed marks the i-th Tasker as completed,
+// saving if the interval since the last Save elapsed.
+func (c *checkpointer) completed(i int) {
+	if c == nil {
+		return
+	}
+	c.done[i] = true
+	c.dirty = true
+	if time.Since(c.saved) >= c.interval {
+		c.save()
+	}
+}
+
+// save calls Save if Taskers completed since the last one.
+func (c *checkpointer) save() {
+	if c == nil || !c.dirty {
+		return
+	}
+	var completed []int
+	for i, d := range c.done {
+		if d {
+			completed = append(completed, i)
+		}
+	}
+	c.cp.Save(completed)
+	c.dirty = false
+	c.saved = time.Now()
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"testing"
+)
+
+// memCheckpoint is a Checkpoint in memory.
+type memCheckpoint struct {
+	completed []int
+	saves     int
+}
+
+func (m *memCheckpoint) Load() []int { return m.completed }
+
+func (m *memCheckpoint) Save(completed []int) {
+	m.completed = completed
+	m.saves++
+}
+
+func TestRunWith_checkpoint(t *testing.T) {
+	c := &counter{}
+	jobs := []Tasker{c, c, c, panicker{}, c}
+	cp := &memCheckpoint{completed: []int{0, 2, 7}}
+	if err := RunWith(jobs, Options{Workers: 2, Checkpoint: cp}); err == nil {
+		t.Fatal("expected the panic error")
+	}
+	if c.n != 2 {
+		t.Fatalf("expected 2 executions, got %d", c.n)
+	}
+	if want := []int{0, 1, 2, 4}; !reflect.DeepEqual(cp.completed, want) {
+		t.Fatalf("expected %v saved, got %v", want, cp.completed)
+	}
+	if cp.saves != 2 {
+		t.Fatalf("expected a save per completed task, got %d", cp.saves)
+	}
+	// With nothing left only the failed Tasker is executed again.
+	cp.saves = 0
+	RunWith(jobs, Options{Checkpoint: cp, CheckpointInterval: 1e9})
+	if c.n != 2 || cp.saves != 0 {
+		t.Fatalf("expected no execution and no save, got %d and %d", c.n, cp.saves)
+	}
+}
+
+func TestRunWith_checkpointResume(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			tasks := interrupted(&sigChan, 1e2)
+			cp := &memCheckpoint{}
+			opts := Options{Workers: n, Checkpoint: cp}
+			if err := RunWith(tasks, opts); err != ErrTasksNotCompleted {
+				t.Fatal("expected ErrTasksNotCompleted, got", err)
+			}
+			if len(cp.completed) == len(tasks) {
+				t.Fatal("all tasks completed")
+			}
+			if err := RunWith(tasks, opts); err != nil {
+				t.Fatal(err)
+			}
+			for i, e := range tasks[1:] {
+				if c := e.(gated).cancelable; c.executed != 1 {
+					t.Fatalf("task %d executed %d times", i, c.executed)
+				}
+			}
+			if len(cp.completed) != len(tasks) {
+				t.Fatalf("expected %d completed tasks, got %d", len(tasks), len(cp.completed))
+			}
+		})
+	}
+}
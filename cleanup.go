@@ -0,0 +1,42 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+// CleanupTasker is a Tasker holding resources, e.g. open files or
+// connections, to release when it does not complete as part of a run.
+// Cleanup is called once, instead of Execute, for a CleanupTasker that
+// is not started because the run has been aborted, after OnCancel if
+// it's a Canceler too. It's called after Execute returns for one that
+// was abandoned while running, on TaskTimeout or once ShutdownGrace
+// or StallTimeout expired. It's not called for completed Taskers.
+type CleanupTasker interface {
+	Tasker
+	Cleanup()
+}
+
+// cleanup calls Cleanup on t, looking through
+// adapters of other kinds of Taskers.
+func cleanup(t Tasker) {
+	var v interface{} = t
+	switch a := t.(type) {
+	case ctxTask:
+		v = a.t
+	case *errTask:
+		v = a.ErrTasker
+	case *goTask:
+		v = a.GoTasker
+	case *predTask:
+		v = a.Tasker
+	case *sumTask:
+		v = a.Tasker
+	case *budgetTask:
+		v = a.Tasker
+	case *dynTask:
+		v = a.Tasker
+	}
+	if c, ok := v.(interface{ Cleanup() }); ok {
+		c.Cleanup()
+	}
+}
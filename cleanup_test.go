@@ -0,0 +1,122 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// closer is a CleanupTasker that waits for gate, if not nil,
+// and sleeps for d.
+type closer struct {
+	gate     <-chan struct{}
+	d        time.Duration
+	executed int32
+	cleaned  int32
+}
+
+func (c *closer) Execute() {
+	if c.gate != nil {
+		<-c.gate
+	}
+	time.Sleep(c.d)
+	atomic.AddInt32(&c.executed, 1)
+}
+
+func (c *closer) Cleanup() { atomic.AddInt32(&c.cleaned, 1) }
+
+func TestRun_cleanupOnInterrupt(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			gate := make(chan struct{})
+			tasks := []Tasker{TaskFunc(func() {
+				sigChan <- os.Interrupt
+				close(gate)
+			})}
+			for i := 0; i < 1e2; i++ {
+				tasks = append(tasks, &closer{gate: gate})
+			}
+			if err := RunWith(tasks, Options{Workers: n}); err != ErrTasksNotCompleted {
+				t.Fatal("expected ErrTasksNotCompleted, got", err)
+			}
+			var cleaned int32
+			for i, e := range tasks[1:] {
+				c := e.(*closer)
+				if c.executed+c.cleaned != 1 {
+					t.Fatalf("task %d executed %d times and cleaned up %d times", i, c.executed, c.cleaned)
+				}
+				cleaned += c.cleaned
+			}
+			if cleaned == 0 {
+				t.Fatal("no task was cleaned up")
+			}
+		})
+	}
+}
+
+// interruptCloser sends a signal, closes sent and
+// then executes closer.
+type interruptCloser struct {
+	*closer
+	c    *chan<- os.Signal
+	sent chan struct{}
+}
+
+func (i interruptCloser) Execute() {
+	*i.c <- os.Interrupt
+	close(i.sent)
+	i.closer.Execute()
+}
+
+func TestRunWith_cleanupAbandoned(t *testing.T) {
+	slow := &closer{d: 50 * time.Millisecond}
+	fast := &closer{}
+	err := RunWith([]Tasker{slow, fast}, Options{Workers: 2, TaskTimeout: 10 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected a timeout")
+	}
+	for i := 0; i < 100 && atomic.LoadInt32(&slow.cleaned) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c := atomic.LoadInt32(&slow.cleaned); c != 1 {
+		t.Fatalf("expected the abandoned task to be cleaned up once, got %d", c)
+	}
+	if fast.cleaned != 0 {
+		t.Fatal("completed task cleaned up")
+	}
+
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	release := make(chan struct{})
+	sent := make(chan struct{})
+	stuck := &closer{gate: release}
+	tasks := []Tasker{interruptCloser{stuck, &sigChan, sent}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &closer{gate: sent})
+	}
+	opts := Options{Workers: 2, ShutdownGrace: 10 * time.Millisecond}
+	if err := RunWith(tasks, opts); err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	if atomic.LoadInt32(&stuck.cleaned) != 0 {
+		t.Fatal("task cleaned up while running")
+	}
+	close(release)
+	for i := 0; i < 100 && atomic.LoadInt32(&stuck.cleaned) == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if c := atomic.LoadInt32(&stuck.cleaned); c != 1 {
+		t.Fatalf("expected the abandoned task to be cleaned up once, got %d", c)
+	}
+}
@@ -0,0 +1,26 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "time"
+
+// CompareSerial times jobs executed by Run against serial, a function
+// doing the same work without parallel, and returns both durations
+// and the speedup, serialDur over parallelDur. It measures whether
+// running in parallel pays off for a workload, setup of workers
+// included. Errors of jobs are ignored: they must be repeatable
+// and succeed for the comparison to be meaningful.
+func CompareSerial(jobs []Tasker, serial func()) (parallelDur, serialDur time.Duration, speedup float64) {
+	pre := time.Now()
+	Run(jobs)
+	parallelDur = time.Since(pre)
+	pre = time.Now()
+	serial()
+	serialDur = time.Since(pre)
+	if parallelDur > 0 {
+		speedup = float64(serialDur) / float64(parallelDur)
+	}
+	return parallelDur, serialDur, speedup
+}
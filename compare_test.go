@@ -0,0 +1,27 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompareSerial(t *testing.T) {
+	withWorkers(t, 4)
+	const d = 10 * time.Millisecond
+	jobs := []Tasker{&sleeper{d}, &sleeper{d}, &sleeper{d}, &sleeper{d}}
+	par, ser, speedup := CompareSerial(jobs, func() {
+		for _, j := range jobs {
+			j.Execute()
+		}
+	})
+	if par < d || ser < 4*d {
+		t.Fatalf("durations too short: %s parallel, %s serial", par, ser)
+	}
+	if speedup <= 1 {
+		t.Fatalf("expected a speedup, got %.2f (%s parallel, %s serial)", speedup, par, ser)
+	}
+}
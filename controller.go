@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+)
+
+// ErrControllerStopped says that a Controller does
+// not accept Taskers anymore.
+var ErrControllerStopped = errors.New("controller is stopped")
+
+// Controller executes Taskers submitted over time with workers that
+// are never expected to run out of work, as a server does. Unlike a
+// run it does not know Taskers in advance: it stops only when Stop
+// is called, a signal is received or its context is done.
+// It's safe for concurrent use.
+type Controller struct {
+	in chan Tasker
+	// mu guards stopped, Submit holds it for
+	// reading while sending to in.
+	mu      sync.RWMutex
+	stopped bool
+	// done is closed once workers returned, then err is set.
+	done chan struct{}
+	err  error
+}
+
+// Start starts workers executing Taskers passed to Submit,
+// opts configures them as in RunSeq.
+func Start(opts Options) (*Controller, error) {
+	return StartContext(context.Background(), opts)
+}
+
+// StartContext is like Start but the Controller stops
+// taking Taskers as soon as ctx is done.
+func StartContext(ctx context.Context, opts Options) (*Controller, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	c := &Controller{in: make(chan Tasker), done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		c.err = runFed(ctx, opts, func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+			return chanQueue(ctx, c.in, jobsQueue, signals)
+		})
+	}()
+	return c, nil
+}
+
+// Submit queues t blocking until a worker can take it. It returns
+// ErrControllerStopped once Stop has been called or the Controller
+// stopped on its own.
+func (c *Controller) Submit(t Tasker) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.stopped {
+		return ErrControllerStopped
+	}
+	select {
+	case c.in <- t:
+		return nil
+	case <-c.done:
+		return ErrControllerStopped
+	}
+}
+
+// Stop waits for submitted Taskers to complete and stops the workers.
+// It returns errors as RunSeq does, Index of a *TaskError being the
+// position of the Tasker among submitted ones. Errors are kept until
+// Stop, calling it again returns the same error.
+func (c *Controller) Stop() error {
+	c.mu.Lock()
+	if !c.stopped {
+		c.stopped = true
+		close(c.in)
+	}
+	c.mu.Unlock()
+	<-c.done
+	return c.err
+}
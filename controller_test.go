@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestController(t *testing.T) {
+	for _, n := range testWorkers {
+		c, err := Start(Options{Workers: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		counters := make([]*counter, 1e2)
+		var wg sync.WaitGroup
+		for i := range counters {
+			counters[i] = &counter{}
+			wg.Add(1)
+			go func(j Tasker) {
+				defer wg.Done()
+				if err := c.Submit(j); err != nil {
+					t.Error(err)
+				}
+			}(counters[i])
+		}
+		wg.Wait()
+		if err := c.Stop(); err != nil {
+			t.Fatal(err)
+		}
+		for i, j := range counters {
+			if j.n != 1 {
+				t.Fatalf("%d workers: task %d executed %d times", n, i, j.n)
+			}
+		}
+		if err := c.Submit(&counter{}); err != ErrControllerStopped {
+			t.Fatal("expected ErrControllerStopped, got", err)
+		}
+	}
+}
+
+func TestController_errors(t *testing.T) {
+	c, err := Start(Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Submit(&counter{})
+	c.Submit(panicker{})
+	err = c.Stop()
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected the error of the second task, got", err)
+	}
+	if again := c.Stop(); again != err {
+		t.Fatal("expected the same error, got", again)
+	}
+}
+
+func TestStart_invalidOptions(t *testing.T) {
+	if _, err := Start(Options{Workers: -1}); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+}
+
+func TestStartContext_done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, err := StartContext(ctx, Options{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for c.Submit(&counter{}) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("controller did not stop")
+		}
+	}
+	if err := c.Stop(); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Files exposing the CFS CPU quota, for cgroup v2 and v1 respectively.
+const (
+	cgroupV2CPUMax    = "/sys/fs/cgroup/cpu.max"
+	cgroupV1CPUQuota  = "/sys/fs/cgroup/cpu/cpu.cfs_quota_us"
+	cgroupV1CPUPeriod = "/sys/fs/cgroup/cpu/cpu.cfs_period_us"
+)
+
+// cgroupNoQuota is the cgroup v2 quota value meaning no limit.
+const cgroupNoQuota = "max"
+
+// defaultWorkers returns the number of workers to use when
+// none is specified. Inside a container runtime.NumCPU returns
+// the cores of the host, so a CPU quota is honored when present.
+func defaultWorkers() int {
+	n := runtime.NumCPU()
+	if q, ok := cgroupCPUs(os.ReadFile); ok && q < n {
+		return q
+	}
+	return n
+}
+
+// cgroupCPUs returns the number of CPUs allowed by the cgroup
+// CPU quota, rounded up. It returns false if no quota is set or
+// it can not be read. read is used to access files.
+func cgroupCPUs(read func(string) ([]byte, error)) (int, bool) {
+	var quota, period string
+	if b, err := read(cgroupV2CPUMax); err == nil {
+		// Format is "$MAX $PERIOD".
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0, false
+		}
+		quota, period = fields[0], fields[1]
+	} else {
+		q, err := read(cgroupV1CPUQuota)
+		if err != nil {
+			return 0, false
+		}
+		p, err := read(cgroupV1CPUPeriod)
+		if err != nil {
+			return 0, false
+		}
+		quota, period = strings.TrimSpace(string(q)), strings.TrimSpace(string(p))
+	}
+	if quota == cgroupNoQuota {
+		return 0, false
+	}
+	q, err := strconv.ParseInt(quota, 10, 64)
+	// cgroup v1 uses -1 for no limit.
+	if err != nil || q <= 0 {
+		return 0, false
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return int((q + p - 1) / p), true
+}
+
+// singleProcWarning makes the GOMAXPROCS warning logged once per process.
+var singleProcWarning sync.Once
+
+// singleProc reports whether goroutines can not run in parallel
+// as GOMAXPROCS is 1, warning l about it the first time.
+func singleProc(l Logger) bool {
+	if runtime.GOMAXPROCS(0) != 1 {
+		return false
+	}
+	singleProcWarning.Do(func() {
+		logEvent(l, "parallel: GOMAXPROCS is 1, parallelism is disabled")
+	})
+	return true
+}
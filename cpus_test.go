@@ -0,0 +1,81 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"sync"
+	"testing"
+)
+
+func fakeFS(files map[string]string) func(string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		c, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return []byte(c), nil
+	}
+}
+
+func TestCgroupCPUs(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		cpus  int
+		ok    bool
+	}{
+		{"no cgroup", nil, 0, false},
+		{"v2 limited", map[string]string{cgroupV2CPUMax: "200000 100000\n"}, 2, true},
+		{"v2 fractional", map[string]string{cgroupV2CPUMax: "150000 100000\n"}, 2, true},
+		{"v2 unlimited", map[string]string{cgroupV2CPUMax: "max 100000\n"}, 0, false},
+		{"v2 malformed", map[string]string{cgroupV2CPUMax: "garbage\n"}, 0, false},
+		{"v1 limited", map[string]string{
+			cgroupV1CPUQuota:  "400000\n",
+			cgroupV1CPUPeriod: "100000\n",
+		}, 4, true},
+		{"v1 unlimited", map[string]string{
+			cgroupV1CPUQuota:  "-1\n",
+			cgroupV1CPUPeriod: "100000\n",
+		}, 0, false},
+		{"v1 missing period", map[string]string{cgroupV1CPUQuota: "400000\n"}, 0, false},
+	}
+	for _, tc := range testCases {
+		cpus, ok := cgroupCPUs(fakeFS(tc.files))
+		if cpus != tc.cpus || ok != tc.ok {
+			t.Errorf("%s: got (%d, %v), expected (%d, %v)", tc.name, cpus, ok, tc.cpus, tc.ok)
+		}
+	}
+}
+
+func TestRunWithStats_serialOnSingleProc(t *testing.T) {
+	singleProcWarning = sync.Once{}
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &counter{}
+	}
+	l := &eventLogger{events: make(map[string]int)}
+	opts := Options{Workers: 4, GOMAXPROCS: 1, SerialOnSingleProc: true, Logger: l}
+	for i := 0; i < 2; i++ {
+		s, err := RunWithStats(tasks, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(s.Workers) != 1 {
+			t.Fatalf("expected a single worker, got %d", len(s.Workers))
+		}
+	}
+	if n := l.events["parallel: GOMAXPROCS is 1, parallelism is disabled"]; n != 1 {
+		t.Fatalf("expected a single warning, got %d", n)
+	}
+	opts.GOMAXPROCS = 2
+	s, err := RunWithStats(tasks, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Workers) != 4 {
+		t.Fatalf("expected 4 workers, got %d", len(s.Workers))
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+//go:build !linux
+
+package parallel
+
+import "time"
+
+// threadCPU tells that CPU time of single threads is not available.
+const threadCPU = false
+
+// threadCPUTime is never called where threadCPU is false.
+func threadCPUTime() time.Duration {
+	return 0
+}
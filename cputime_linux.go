@@ -0,0 +1,25 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+//go:build linux
+
+package parallel
+
+import (
+	"syscall"
+	"time"
+)
+
+// threadCPU tells that CPU time of single threads is available.
+const threadCPU = true
+
+// threadCPUTime returns user plus system CPU time consumed by the
+// calling thread, the goroutine must be locked to it.
+func threadCPUTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_THREAD, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
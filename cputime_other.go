@@ -0,0 +1,15 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+//go:build !unix
+
+package parallel
+
+import "time"
+
+// cpuTime falls back to wall-clock time where
+// process CPU time is not available.
+func cpuTime() time.Duration {
+	return wallTime()
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+//go:build unix
+
+package parallel
+
+import (
+	"syscall"
+	"time"
+)
+
+// cpuTime returns user plus system CPU time consumed by the process.
+func cpuTime() time.Duration {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return wallTime()
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+}
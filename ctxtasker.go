@@ -0,0 +1,57 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "context"
+
+// CtxTasker models an heavy task that can stop
+// early once ctx is done.
+type CtxTasker interface {
+	Execute(ctx context.Context)
+}
+
+// RunCtxTasks is like RunContext but Taskers receive the context of
+// the run, done when ctx is, on timeout or when the run is aborted,
+// so that long running ones can return early.
+func RunCtxTasks(ctx context.Context, jobs []CtxTasker) error {
+	tasks := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		if j != nil {
+			tasks[i] = ctxTask{j}
+		}
+	}
+	return RunContext(ctx, tasks)
+}
+
+// ctxExecutor is implemented by Taskers that accept
+// a context and can fail.
+type ctxExecutor interface {
+	executeCtx(ctx context.Context) error
+}
+
+// ctxTask adapts a CtxTasker to Tasker, workers
+// use executeCtx to pass the context.
+type ctxTask struct {
+	t CtxTasker
+}
+
+func (c ctxTask) Execute() {
+	c.t.Execute(context.Background())
+}
+
+func (c ctxTask) executeCtx(ctx context.Context) error {
+	c.t.Execute(ctx)
+	return nil
+}
+
+// executeContext is like execute but passes
+// ctx to Taskers that accept it.
+func executeContext(ctx context.Context, t Tasker) (err error) {
+	if c, ok := t.(ctxExecutor); ok {
+		defer recoverPanic(&err)
+		return c.executeCtx(ctx)
+	}
+	return execute(t)
+}
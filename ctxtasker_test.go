@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// blocker waits for its context to be done,
+// closing started and stopped.
+type blocker struct {
+	started, stopped chan struct{}
+}
+
+func newBlocker() blocker {
+	return blocker{make(chan struct{}), make(chan struct{})}
+}
+
+func (b blocker) Execute(ctx context.Context) {
+	close(b.started)
+	select {
+	case <-ctx.Done():
+		close(b.stopped)
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// ctxCanceler cancels once b started.
+type ctxCanceler struct {
+	b      blocker
+	cancel context.CancelFunc
+}
+
+func (c ctxCanceler) Execute(context.Context) {
+	<-c.b.started
+	c.cancel()
+}
+
+func TestRunCtxTasks(t *testing.T) {
+	withWorkers(t, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := newBlocker()
+	err := RunCtxTasks(ctx, []CtxTasker{b, ctxCanceler{b, cancel}})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatal("expected nil or context.Canceled, got", err)
+	}
+	select {
+	case <-b.stopped:
+	default:
+		t.Fatal("task did not receive cancellation")
+	}
+}
+
+func TestRunWith_taskTimeoutContext(t *testing.T) {
+	b := newBlocker()
+	err := RunWith([]Tasker{ctxTask{b}}, Options{TaskTimeout: 10 * time.Millisecond})
+	if !errors.Is(err, ErrTaskTimeout) {
+		t.Fatal("expected ErrTaskTimeout, got", err)
+	}
+	// The abandoned task must stop soon after.
+	select {
+	case <-b.stopped:
+	case <-time.After(time.Second):
+		t.Fatal("task did not receive the timeout")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeadlineTasker is a Tasker that must be executed before
+// an absolute deadline, e.g. the SLA of the request it serves.
+// A worker taking it once the deadline passed does not execute
+// it and records an error wrapping ErrTaskTimeout. A CtxTasker
+// implementing it receives a context done at the deadline.
+type DeadlineTasker interface {
+	Tasker
+	Deadline() time.Time
+}
+
+// deadline returns the deadline of t, looking through
+// adapters of other kinds of Taskers.
+func deadline(t Tasker) (time.Time, bool) {
+	var v interface{} = t
+	switch a := t.(type) {
+	case ctxTask:
+		v = a.t
+	case *errTask:
+		v = a.ErrTasker
+	case *goTask:
+		v = a.GoTasker
+	}
+	if d, ok := v.(interface{ Deadline() time.Time }); ok {
+		return d.Deadline(), true
+	}
+	return time.Time{}, false
+}
+
+// withDeadline returns ctx done at the deadline of t, if any, or
+// an error wrapping ErrTaskTimeout if the deadline already passed.
+func withDeadline(ctx context.Context, t Tasker) (context.Context, context.CancelFunc, error) {
+	d, ok := deadline(t)
+	if !ok {
+		return ctx, func() {}, nil
+	}
+	if !time.Now().Before(d) {
+		return ctx, func() {}, fmt.Errorf("%w: deadline %s passed", ErrTaskTimeout, d.Format(time.RFC3339Nano))
+	}
+	ctx, cancel := context.WithDeadline(ctx, d)
+	return ctx, cancel, nil
+}
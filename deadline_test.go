@@ -0,0 +1,60 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// sla is a dummy with a deadline.
+type sla struct {
+	dummy
+	deadline time.Time
+}
+
+func (s *sla) Deadline() time.Time { return s.deadline }
+
+// ctxSla waits for its context, done at its deadline.
+type ctxSla struct {
+	deadline time.Time
+	err      error
+}
+
+func (c *ctxSla) Execute(ctx context.Context) {
+	<-ctx.Done()
+	c.err = ctx.Err()
+}
+
+func (c *ctxSla) Deadline() time.Time { return c.deadline }
+
+func TestRunWith_deadline(t *testing.T) {
+	for _, w := range testWorkers {
+		expired := &sla{deadline: time.Now().Add(-time.Second)}
+		valid := &sla{deadline: time.Now().Add(time.Hour)}
+		err := RunWith([]Tasker{expired, valid, &dummy{}}, Options{Workers: w})
+		var te *TaskError
+		if !errors.As(err, &te) || te.Index != 0 || !errors.Is(err, ErrTaskTimeout) {
+			t.Fatalf("workers=%d: expected ErrTaskTimeout for task 0, got %v", w, err)
+		}
+		if expired.done || !valid.done {
+			t.Fatalf("workers=%d: expired task executed or valid one skipped", w)
+		}
+	}
+	p := NewPool(2)
+	defer p.Close()
+	if err := p.Submit([]Tasker{&sla{deadline: time.Now().Add(-time.Second)}}); !errors.Is(err, ErrTaskTimeout) {
+		t.Fatal("expected ErrTaskTimeout from the Pool, got", err)
+	}
+	c := &ctxSla{deadline: time.Now().Add(10 * time.Millisecond)}
+	if err := RunCtxTasks(context.Background(), []CtxTasker{c}); err != nil {
+		t.Fatal(err)
+	}
+	if c.err != context.DeadlineExceeded {
+		t.Fatal("expected context.DeadlineExceeded, got", c.err)
+	}
+}
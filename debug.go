@@ -0,0 +1,36 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"log/slog"
+	"time"
+)
+
+// RunDebug executes jobs one at a time in the calling goroutine,
+// in submission order, sending start, end and duration of every
+// Tasker to l (slog.Default() if nil).
+// It helps telling apart bugs in Taskers from issues that only show up
+// under parallelism. Panics, nil Taskers and errors are handled and
+// returned as in Run, a failing Tasker is logged with its error.
+func RunDebug(jobs []Tasker, l Logger) error {
+	if l == nil {
+		l = slog.Default()
+	}
+	r := newReport(jobs)
+	for i, j := range jobs {
+		l.Info("parallel: task start", "task", i)
+		pre := time.Now()
+		err := execute(j)
+		if err != nil {
+			l.Info("parallel: task failed", "task", i, "duration", time.Since(pre), "err", err)
+		} else {
+			l.Info("parallel: task end", "task", i, "duration", time.Since(pre))
+		}
+		r.errs[i] = err
+		r.executed[i] = true
+	}
+	return r.error()
+}
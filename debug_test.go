@@ -0,0 +1,86 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type recorder struct {
+	i     int
+	order *[]int
+}
+
+func (r *recorder) Execute() { *r.order = append(*r.order, r.i) }
+
+type panicker struct{}
+
+func (panicker) Execute() { panic("boom") }
+
+// debugLogger returns a Logger writing text lines
+// without time to buf.
+func debugLogger(buf *bytes.Buffer) Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestRunDebug(t *testing.T) {
+	var order []int
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = Tasker(&recorder{i: i, order: &order})
+	}
+	var buf bytes.Buffer
+	if err := RunDebug(tasks, debugLogger(&buf)); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("task %d executed at position %d", v, i)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2*len(tasks) {
+		t.Fatalf("expected %d trace lines, got %d", 2*len(tasks), len(lines))
+	}
+	for i := range tasks {
+		if lines[2*i] != fmt.Sprintf(`msg="parallel: task start" task=%d`, i) {
+			t.Fatalf("unexpected trace line %q", lines[2*i])
+		}
+		if !strings.HasPrefix(lines[2*i+1], fmt.Sprintf(`msg="parallel: task end" task=%d duration=`, i)) {
+			t.Fatalf("unexpected trace line %q", lines[2*i+1])
+		}
+	}
+}
+
+func TestRunDebug_panic(t *testing.T) {
+	var buf bytes.Buffer
+	c := &counter{}
+	err := RunDebug([]Tasker{panicker{}, nil, c}, debugLogger(&buf))
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	if !errors.Is(err, ErrNilTask) {
+		t.Fatal("expected ErrNilTask, got", err)
+	}
+	if c.n != 1 {
+		t.Fatal("task after a panic was not executed")
+	}
+	if !strings.Contains(buf.String(), `msg="parallel: task failed" task=0`) {
+		t.Fatal("panic was not logged")
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// dynQueue feeds workers Taskers that running ones can add to, see
+// RunRecursive and RunSplit. It's evaluated as RunSeq does, until no
+// Tasker is queued or running.
+type dynQueue struct {
+	// splitAfter and maxDepth tell when Splitters are split,
+	// never if maxDepth is zero.
+	splitAfter time.Duration
+	maxDepth   int
+	mu         sync.Mutex
+	items      []indexedTask
+	// outstanding counts queued and running Taskers.
+	outstanding int
+	// stopped tells that feed returned early,
+	// Taskers added afterwards are canceled.
+	stopped bool
+	// changed is signaled when items or outstanding change.
+	changed chan struct{}
+}
+
+func newDynQueue(jobs []Tasker) *dynQueue {
+	q := &dynQueue{changed: make(chan struct{}, 1)}
+	for i, j := range jobs {
+		q.add(i, 0, j)
+	}
+	return q
+}
+
+// run executes Taskers of q.
+func (q *dynQueue) run(ctx context.Context, opts Options) error {
+	return runFed(ctx, opts, func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+		return q.feed(ctx, jobsQueue, signals)
+	})
+}
+
+// add queues ts, derived from the index-th Tasker
+// of jobs at depth splits from it.
+func (q *dynQueue) add(index, depth int, ts ...Tasker) {
+	items := make([]indexedTask, len(ts))
+	for i, t := range ts {
+		items[i] = indexedTask{index, &dynTask{Tasker: t, index: index, depth: depth, q: q}}
+	}
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		cancelRemaining(items)
+		return
+	}
+	q.items = append(q.items, items...)
+	q.outstanding += len(items)
+	q.mu.Unlock()
+	q.signal()
+}
+
+// done tells that a Tasker of q is done.
+func (q *dynQueue) done() {
+	q.mu.Lock()
+	q.outstanding--
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *dynQueue) signal() {
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+}
+
+// feed sends Taskers of q to jobsQueue, in order, closing it once no
+// Tasker is queued or running. It returns an error if a signal or ctx
+// stopped it early, as seqQueue does.
+func (q *dynQueue) feed(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+	defer close(jobsQueue)
+	signalChan := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		notifySignal(signalChan, signals...)
+		defer signal.Stop(signalChan)
+	}
+	for {
+		q.mu.Lock()
+		if q.outstanding == 0 {
+			q.mu.Unlock()
+			return nil
+		}
+		// Without queued Taskers out is nil and
+		// only changes are waited for.
+		var out chan<- indexedTask
+		var next indexedTask
+		if len(q.items) > 0 {
+			out, next = jobsQueue, q.items[0]
+		}
+		q.mu.Unlock()
+		select {
+		case out <- next:
+			// Only feed takes items, next is still the first one.
+			q.mu.Lock()
+			q.items = q.items[1:]
+			q.mu.Unlock()
+		case <-q.changed:
+		case <-signalChan:
+			q.cancel()
+			return ErrTasksNotCompleted
+		case <-ctx.Done():
+			q.cancel()
+			return fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+		}
+	}
+}
+
+// cancel cancels queued Taskers as populateQueue does.
+func (q *dynQueue) cancel() {
+	q.mu.Lock()
+	items := q.items
+	q.items = nil
+	q.stopped = true
+	q.mu.Unlock()
+	cancelRemaining(items)
+}
+
+// dynTask is a Tasker of a dynQueue, executed by workers with
+// executeCtx. RecursiveTaskers spawn to the queue and Splitters
+// still running after splitAfter are split in it.
+type dynTask struct {
+	Tasker
+	index, depth int
+	q            *dynQueue
+}
+
+func (t *dynTask) Execute() {
+	t.executeCtx(context.Background())
+}
+
+func (t *dynTask) executeCtx(ctx context.Context) (err error) {
+	defer t.q.done()
+	if r, ok := t.Tasker.(recursiveTask); ok {
+		defer recoverPanic(&err)
+		// Spawned Taskers have index -1.
+		r.r.Execute(func(s Tasker) { t.q.add(-1, 0, s) })
+		return nil
+	}
+	if s, ok := t.Tasker.(Splitter); ok && t.depth < t.q.maxDepth {
+		return t.split(ctx, s)
+	}
+	return executeContext(ctx, t.Tasker)
+}
+
+// split executes s queuing its sub-Taskers in its place if it's not
+// done within splitAfter. s is abandoned then, its error discarded
+// like what it computes.
+func (t *dynTask) split(ctx context.Context, s Splitter) error {
+	done := make(chan error, 1)
+	go func() { done <- executeContext(ctx, s) }()
+	timer := time.NewTimer(t.q.splitAfter)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		t.q.add(t.index, t.depth+1, s.Split()...)
+		return nil
+	}
+}
+
+// OnCancel notifies the wrapped Tasker, if it's a Canceler.
+func (t *dynTask) OnCancel() {
+	if c, ok := t.Tasker.(Canceler); ok {
+		c.OnCancel()
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TaskError is the error of a single Tasker,
+// returned joined with other errors by runs.
+type TaskError struct {
+	// Index is the index of Task in jobs, -1 for Taskers
+	// not in jobs, e.g. spawned by another Tasker.
+	Index int
+	// Name is the name of Task in runs of named
+	// Taskers, e.g. RunGraph, empty otherwise.
+	Name string
+	// Task is the Tasker that failed, it can be executed again.
+	Task Tasker
+	// Err is the error of Task, e.g. a *PanicError.
+	Err error
+}
+
+func (e *TaskError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("task %q: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("task %d: %v", e.Index, e.Err)
+}
+
+func (e *TaskError) Unwrap() error {
+	return e.Err
+}
+
+// Errors is returned by runs using ErrCollectAll.
+type Errors struct {
+	// Err is the error that aborted the run, if any.
+	Err error
+	// Tasks are errors of single Taskers in jobs order,
+	// nil for Taskers that succeeded or were not executed.
+	Tasks []error
+	// abortedBy is 1 plus the index of the Tasker
+	// whose error is Err, 0 if Err is not a Tasker error.
+	abortedBy int
+}
+
+func (e *Errors) Error() string {
+	var msgs []string
+	if e.Err != nil {
+		msgs = append(msgs, e.Err.Error())
+	}
+	for i, err := range e.Tasks {
+		if err != nil && i != e.abortedBy-1 {
+			msgs = append(msgs, fmt.Sprintf("task %d: %v", i, err))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns all non nil errors, so that
+// errors.Is and errors.As inspect every one.
+func (e *Errors) Unwrap() []error {
+	var errs []error
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	for i, err := range e.Tasks {
+		if err != nil && i != e.abortedBy-1 {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestRunWith_errorMode(t *testing.T) {
+	tasks := []Tasker{&dummy{}, panicker{}, &dummy{}, panicker{}}
+	var p *PanicError
+
+	err := RunWith(tasks, Options{ErrorMode: ErrFirst})
+	if !errors.As(err, &p) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	if _, ok := err.(interface{ Unwrap() []error }); ok {
+		t.Fatal("expected a single error, got", err)
+	}
+
+	err = RunWith(tasks, Options{ErrorMode: ErrCollectAll})
+	var all *Errors
+	if !errors.As(err, &all) {
+		t.Fatal("expected *Errors, got", err)
+	}
+	if len(all.Tasks) != len(tasks) || all.Tasks[0] != nil || all.Tasks[1] == nil || all.Tasks[3] == nil {
+		t.Fatal("unexpected errors", all.Tasks)
+	}
+	if !errors.As(err, &p) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+
+	err = RunWith(tasks, Options{ErrorMode: ErrJoin, Strategy: StrategyWaitGroup})
+	if len(err.(interface{ Unwrap() []error }).Unwrap()) != 2 {
+		t.Fatal("expected 2 joined errors, got", err)
+	}
+
+	if err := RunWith(tasks, Options{ErrorMode: ErrCollectAll + 1}); err != ErrInvalidErrorMode {
+		t.Fatal("expected ErrInvalidErrorMode, got", err)
+	}
+	initTests()
+	if err := RunWith(testCases, Options{ErrorMode: ErrCollectAll}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun_taskError(t *testing.T) {
+	c := &counter{}
+	tasks := []Tasker{&dummy{}, panicker{}, c}
+	err := RunWith(tasks, Options{Workers: 2})
+	var te *TaskError
+	if !errors.As(err, &te) {
+		t.Fatal("expected a *TaskError, got", err)
+	}
+	if te.Index != 1 || te.Task != tasks[1] {
+		t.Fatalf("unexpected task %d %v", te.Index, te.Task)
+	}
+	var p *PanicError
+	if !errors.As(te, &p) {
+		t.Fatal("expected a *PanicError, got", te.Err)
+	}
+	if err := RunSync(tasks[1:]); !errors.As(err, &te) || te.Index != 0 {
+		t.Fatal("expected a *TaskError for task 0, got", err)
+	}
+}
+
+// barrier fails once all its peers started.
+type barrier struct{ wg *sync.WaitGroup }
+
+func (b barrier) Execute() error {
+	b.wg.Done()
+	b.wg.Wait()
+	return errOdd
+}
+
+func TestRunWith_failFastSameError(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	opts := Options{Workers: 2, FailFast: true, ErrorMode: ErrCollectAll}
+	tasks := errTasks([]ErrTasker{barrier{&wg}, barrier{&wg}}, opts)
+	err := RunWith(tasks, opts)
+	var all *Errors
+	if !errors.As(err, &all) || all.Tasks[0] == nil || all.Tasks[1] == nil {
+		t.Fatal("expected errors of both tasks, got", err)
+	}
+	if n := len(all.Unwrap()); n != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", n, err)
+	}
+	wg.Add(2)
+	opts.ErrorMode = ErrJoin
+	err = RunWith(errTasks([]ErrTasker{barrier{&wg}, barrier{&wg}}, opts), opts)
+	if n := len(err.(interface{ Unwrap() []error }).Unwrap()); n != 2 {
+		t.Fatalf("expected 2 joined errors, got %d: %v", n, err)
+	}
+}
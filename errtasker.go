@@ -0,0 +1,222 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// ErrTasker interface models an heavy task that can fail.
+type ErrTasker interface {
+	Execute() error
+}
+
+// RunErr executes jobs like Run and returns their errors in the same
+// order of jobs, Taskers that succeeded yield a nil.
+// A panicking Tasker yields a *PanicError.
+// If the run is aborted Taskers that were not executed yield the
+// error that aborted it (e.g. ErrTasksNotCompleted).
+func RunErr(jobs []ErrTasker) []error {
+	return RunErrWith(jobs, Options{})
+}
+
+// RunErrWith is like RunErr but uses opts for this run only.
+func RunErrWith(jobs []ErrTasker, opts Options) []error {
+	r := runTasks(context.Background(), errTasks(jobs, opts), opts)
+	if r.err != nil {
+		for i := range r.errs {
+			if !r.executed[i] {
+				r.errs[i] = r.err
+			}
+		}
+	}
+	return r.errs
+}
+
+// errTasks wraps jobs into Taskers.
+func errTasks(jobs []ErrTasker, opts Options) []Tasker {
+	tasks := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		tasks[i] = &errTask{
+			ErrTasker: j,
+			retries:   opts.MaxRetries,
+			backoff:   opts.RetryBackoff,
+			jitter:    opts.RetryJitter,
+			metrics:   opts.Metrics,
+			queued:    opts.RetryQueue > 0,
+		}
+	}
+	return tasks
+}
+
+// errTask adapts an ErrTasker to Tasker, workers use
+// executeErr to retrieve its error.
+type errTask struct {
+	ErrTasker
+	retries int
+	backoff time.Duration
+	jitter  float64
+	metrics Metrics
+	// queued makes executeCtx attempt once, retries
+	// go through the retry queue of the run.
+	queued bool
+	// attempts made so far when queued,
+	// resume is when the backoff after the last one ends.
+	attempts int
+	resume   time.Time
+}
+
+func (e *errTask) Execute() {
+	e.executeErr()
+}
+
+func (e *errTask) executeErr() error {
+	return e.executeCtx(context.Background())
+}
+
+// executeCtx executes e retrying it on failure, no more retries
+// start once ctx is done. The last error is returned.
+func (e *errTask) executeCtx(ctx context.Context) error {
+	if e.ErrTasker == nil {
+		return ErrNilTask
+	}
+	if e.queued {
+		e.attempts++
+		return e.attempt()
+	}
+	for attempt := 0; ; attempt++ {
+		err := e.attempt()
+		if err == nil || attempt >= e.retries || !sleep(ctx, e.pause()) {
+			return err
+		}
+		if e.metrics != nil {
+			e.metrics.Retried()
+		}
+	}
+}
+
+// sleep pauses for d, it reports false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (e *errTask) attempt() (err error) {
+	defer recoverPanic(&err)
+	return e.ErrTasker.Execute()
+}
+
+// retriable reports whether a queued e that failed has
+// retries left.
+func (e *errTask) retriable() bool {
+	return e.queued && e.attempts <= e.retries
+}
+
+// pause returns the backoff before the next retry of e,
+// randomized within ±jitter of it. The rand/v2 top level source
+// is per thread, workers do not contend for it.
+func (e *errTask) pause() time.Duration {
+	if e.jitter == 0 {
+		return e.backoff
+	}
+	return time.Duration(float64(e.backoff) * (1 + e.jitter*(2*rand.Float64()-1)))
+}
+
+// wait pauses until the backoff since the last failure of e
+// elapsed, it reports false if ctx is done first.
+func (e *errTask) wait(ctx context.Context) bool {
+	return sleep(ctx, time.Until(e.resume))
+}
+
+// retry handles the failure of j with err: an ErrTasker with retries
+// left is handed to enqueue, reporting true, or is retried in place
+// if enqueue refuses it, the last error is returned. Taskers refused
+// by an open circuit breaker are not retried.
+func retry(ctx context.Context, j indexedTask, err error, opts Options, enqueue func(indexedTask) bool) (bool, error) {
+	e, ok := j.Tasker.(*errTask)
+	for ok && err != nil && err != ErrCircuitOpen && e.retriable() {
+		if e.metrics != nil {
+			e.metrics.Retried()
+		}
+		e.resume = time.Now().Add(e.pause())
+		if enqueue(j) {
+			return true, nil
+		}
+		if !e.wait(ctx) {
+			break
+		}
+		err = opts.CircuitBreaker.execute(ctx, j, opts.TaskTimeout)
+	}
+	return false, err
+}
+
+// retryQueue holds failed ErrTaskers waiting
+// to be retried, see Options.RetryQueue.
+type retryQueue chan indexedTask
+
+// enqueue queues j unless q is full.
+func (q retryQueue) enqueue(j indexedTask) bool {
+	select {
+	case q <- j:
+		return true
+	default:
+		return false
+	}
+}
+
+// next returns the Tasker a worker must execute, preferring fresh
+// ones from jobs to retries, retry tells which one it is. ok is false
+// once jobs is closed and no retry is waiting: retries queued later
+// are taken by the workers that queued them.
+func (q retryQueue) next(jobs *workerQueue) (j indexedTask, retry, ok bool) {
+	if j, ok = jobs.poll(); ok {
+		return j, false, true
+	}
+	for !jobs.closed() {
+		select {
+		case j, ok = <-jobs.own:
+			if ok {
+				return j, false, true
+			}
+			jobs.own = nil
+		case j, ok = <-jobs.shared:
+			if ok {
+				return j, false, true
+			}
+			jobs.shared = nil
+		case j = <-q:
+			return j, true, true
+		}
+	}
+	// Queues of other workers are closed too, take what is left.
+	if j, ok = jobs.stolen(); ok {
+		return j, false, true
+	}
+	return q.left()
+}
+
+// left returns a waiting retry, if any.
+func (q retryQueue) left() (indexedTask, bool, bool) {
+	select {
+	case j := <-q:
+		return j, true, true
+	default:
+		return indexedTask{}, false, false
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errOdd = errors.New("odd task")
+
+type failOdd struct {
+	i int
+}
+
+func (f *failOdd) Execute() error {
+	if f.i%2 == 1 {
+		return errOdd
+	}
+	return nil
+}
+
+func TestRunErr(t *testing.T) {
+	tasks := make([]ErrTasker, 1e2)
+	for i := range tasks {
+		tasks[i] = ErrTasker(&failOdd{i: i})
+	}
+	errs := RunErr(tasks)
+	if len(errs) != len(tasks) {
+		t.Fatalf("expected %d errors, got %d", len(tasks), len(errs))
+	}
+	for i, err := range errs {
+		if i%2 == 1 && err != errOdd {
+			t.Fatalf("task %d: expected errOdd, got %v", i, err)
+		}
+		if i%2 == 0 && err != nil {
+			t.Fatalf("task %d: expected nil, got %v", i, err)
+		}
+	}
+}
+
+type panickerErr struct{}
+
+func (panickerErr) Execute() error { panic("boom") }
+
+func TestRunErr_panic(t *testing.T) {
+	errs := RunErr([]ErrTasker{&failOdd{i: 0}, panickerErr{}})
+	if errs[0] != nil {
+		t.Fatal("expected nil, got", errs[0])
+	}
+	if _, ok := errs[1].(*PanicError); !ok {
+		t.Fatal("expected a *PanicError, got", errs[1])
+	}
+}
+
+// flaky fails the first failures executions.
+type flaky struct {
+	failures int
+	attempts int
+}
+
+func (f *flaky) Execute() error {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return errOdd
+	}
+	return nil
+}
+
+func TestRunErrWith_retries(t *testing.T) {
+	tasks := []ErrTasker{&flaky{failures: 2}, &flaky{failures: 5}}
+	errs := RunErrWith(tasks, Options{MaxRetries: 3, RetryBackoff: time.Millisecond})
+	if errs[0] != nil {
+		t.Fatal("expected task to succeed after retries, got", errs[0])
+	}
+	if a := tasks[0].(*flaky).attempts; a != 3 {
+		t.Fatalf("expected 3 attempts, got %d", a)
+	}
+	if errs[1] != errOdd {
+		t.Fatal("expected errOdd after exhausting retries, got", errs[1])
+	}
+	if a := tasks[1].(*flaky).attempts; a != 4 {
+		t.Fatalf("expected 4 attempts, got %d", a)
+	}
+	errs = RunErrWith(tasks, Options{MaxRetries: -1})
+	for _, err := range errs {
+		if err != ErrInvalidRetries {
+			t.Fatal("expected ErrInvalidRetries, got", err)
+		}
+	}
+}
+
+// failing always fails, counting attempts.
+type failing struct {
+	attempts int32
+}
+
+func (f *failing) Execute() error {
+	atomic.AddInt32(&f.attempts, 1)
+	return errOdd
+}
+
+func TestRunErrWith_retriesAborted(t *testing.T) {
+	f := &failing{}
+	opts := Options{Workers: 2, MaxRetries: 1e3, RetryBackoff: 5 * time.Millisecond, Timeout: 20 * time.Millisecond}
+	start := time.Now()
+	errs := RunErrWith([]ErrTasker{f}, opts)
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("retries kept going after the timeout, run took %s", d)
+	}
+	if errs[0] != errOdd {
+		t.Fatal("expected errOdd, got", errs[0])
+	}
+	attempts := atomic.LoadInt32(&f.attempts)
+	time.Sleep(20 * time.Millisecond)
+	if a := atomic.LoadInt32(&f.attempts); a != attempts {
+		t.Fatalf("%d attempts after the run returned", a-attempts)
+	}
+}
+
+// orderedErr records its index executing, failing
+// the first failures executions.
+type orderedErr struct {
+	i, failures int
+	delay       time.Duration
+	order       *[]int
+}
+
+func (o *orderedErr) Execute() error {
+	time.Sleep(o.delay)
+	*o.order = append(*o.order, o.i)
+	if o.failures > 0 {
+		o.failures--
+		return errOdd
+	}
+	return nil
+}
+
+func TestRunErrWith_retryQueue(t *testing.T) {
+	for _, c := range []struct {
+		failures []int
+		capacity int
+		expected []int
+	}{
+		{[]int{2, 0, 0, 0}, 4, []int{0, 1, 2, 3, 0, 0}},
+		// The second failure does not fit the queue.
+		{[]int{1, 1, 0}, 1, []int{0, 1, 1, 2, 0}},
+	} {
+		for _, opts := range []Options{
+			{Workers: 1},
+			// No serial path, so the retry queue is a channel.
+			{Workers: 1, ShutdownGrace: time.Second, QueueDepth: 10},
+		} {
+			var order []int
+			tasks := make([]ErrTasker, len(c.failures))
+			for i, f := range c.failures {
+				// Let the queue fill up before the first failure.
+				tasks[i] = &orderedErr{i: i, failures: f, delay: time.Duration(10/(i+1)) * time.Millisecond, order: &order}
+			}
+			opts.MaxRetries = 2
+			opts.RetryQueue = c.capacity
+			for i, err := range RunErrWith(tasks, opts) {
+				if err != nil {
+					t.Fatalf("task %d: %v", i, err)
+				}
+			}
+			if !reflect.DeepEqual(order, c.expected) {
+				t.Fatalf("%+v: expected order %v, got %v", opts, c.expected, order)
+			}
+		}
+	}
+}
+
+type countErr struct {
+	n int32
+}
+
+func (c *countErr) Execute() error {
+	atomic.AddInt32(&c.n, 1)
+	// Leave time to stop the run.
+	time.Sleep(time.Millisecond)
+	return nil
+}
+
+func TestRunErrWith_failFast(t *testing.T) {
+	for _, w := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", w), func(t *testing.T) {
+			tasks := []ErrTasker{&failOdd{i: 1}}
+			for i := 0; i < 1e2; i++ {
+				tasks = append(tasks, &countErr{})
+			}
+			errs := RunErrWith(tasks, Options{Workers: w, FailFast: true})
+			if errs[0] != errOdd {
+				t.Fatal("expected errOdd, got", errs[0])
+			}
+			var executed int
+			for i, e := range tasks[1:] {
+				if n := atomic.LoadInt32(&e.(*countErr).n); n == 0 {
+					if errs[i+1] != errOdd {
+						t.Fatalf("task %d not executed, expected errOdd got %v", i+1, errs[i+1])
+					}
+				} else {
+					executed++
+				}
+			}
+			if executed == len(tasks)-1 {
+				t.Fatal("run did not stop at first failure")
+			}
+			err := RunWith([]Tasker{panicker{}, &dummy{}, &dummy{}}, Options{Workers: w, FailFast: true})
+			if te, ok := err.(*TaskError); !ok || te.Index != 0 {
+				t.Fatal("expected only a *TaskError, got", err)
+			} else if _, ok := te.Err.(*PanicError); !ok {
+				t.Fatal("expected a *PanicError, got", te.Err)
+			}
+		})
+	}
+}
+
+func TestRunErrWith_maxErrors(t *testing.T) {
+	var tasks []ErrTasker
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &failOdd{i: i})
+	}
+	errs := RunErrWith(tasks, Options{Workers: 1, MaxErrors: 3})
+	var failed, aborted int
+	for _, err := range errs {
+		switch err {
+		case errOdd:
+			failed++
+		case ErrTooManyErrors:
+			aborted++
+		}
+	}
+	// Tasks already started when the threshold
+	// is reached can fail too.
+	if failed < 3 || aborted == 0 {
+		t.Fatalf("expected at least 3 failures and aborted tasks, got %d and %d", failed, aborted)
+	}
+	jobs := []Tasker{panicker{}, panicker{}, &dummy{}}
+	err := RunWith(jobs, Options{Workers: 1, MaxErrors: 2})
+	var p *PanicError
+	if !errors.Is(err, ErrTooManyErrors) || !errors.As(err, &p) {
+		t.Fatal("expected ErrTooManyErrors and a *PanicError, got", err)
+	}
+	if err := RunWith(jobs, Options{MaxErrors: 3}); errors.Is(err, ErrTooManyErrors) {
+		t.Fatal("unexpected ErrTooManyErrors")
+	}
+	if err := RunWith(jobs, Options{MaxErrors: -1}); err != ErrInvalidMaxErrors {
+		t.Fatal("expected ErrInvalidMaxErrors, got", err)
+	}
+}
+
+func TestRunErr_nilTask(t *testing.T) {
+	errs := RunErr([]ErrTasker{nil, &failOdd{1}, nil})
+	if errs[0] != ErrNilTask || errs[2] != ErrNilTask {
+		t.Fatal("expected ErrNilTask, got", errs)
+	}
+	if errs[1] == nil {
+		t.Fatal("expected an error from task 1")
+	}
+}
+
+func TestErrTask_jitter(t *testing.T) {
+	e := &errTask{backoff: 10 * time.Millisecond}
+	if d := e.pause(); d != e.backoff {
+		t.Fatalf("expected %s without jitter, got %s", e.backoff, d)
+	}
+	e.jitter = 0.5
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1e3; i++ {
+		d := e.pause()
+		if d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("pause %s outside ±50%% of %s", d, e.backoff)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Fatal("pauses not randomized")
+	}
+	opts := Options{RetryJitter: 1.5}
+	if errs := RunErrWith([]ErrTasker{&countErr{}}, opts); errs[0] != ErrInvalidJitter {
+		t.Fatal("expected ErrInvalidJitter, got", errs[0])
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// RunG is like Run but accepts a slice of any
+// Tasker implementation, saving the caller the
+// conversion of []T to []Tasker.
+func RunG[T Tasker](jobs []T) error {
+	tasks := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		tasks[i] = j
+	}
+	return Run(tasks)
+}
+
+// Map applies f to every input in parallel using workers workers,
+// zero or less meaning the package default. Outputs are returned in
+// the same order of inputs. Signals are not trapped and
+// a panic of f is propagated to the caller as a *PanicError.
+func Map[In, Out any](inputs []In, f func(In) Out, workers int) []Out {
+	outputs := make([]Out, len(inputs))
+	tasks := make([]Tasker, len(inputs))
+	for i := range inputs {
+		// Every Tasker writes its own slot, no locking is needed.
+		tasks[i] = &mapTask[In, Out]{f: f, in: inputs[i], out: &outputs[i]}
+	}
+	runHelper(tasks, workers)
+	return outputs
+}
+
+// Pipe is Map applying stage2 to the output of stage1: every
+// input goes through both stages, one after the other, on the same
+// worker. It's not a pipeline, stages do not run on workers of their
+// own, but intermediate values are not collected in a slice as two
+// Map calls would do. A panic of either stage is propagated.
+func Pipe[A, B, C any](inputs []A, stage1 func(A) B, stage2 func(B) C, workers int) []C {
+	return Map(inputs, func(a A) C { return stage2(stage1(a)) }, workers)
+}
+
+// Transform replaces every item with f applied to it, in parallel
+// using workers workers as Map does. items is modified in place
+// and returned for convenience. A panic of f is propagated.
+func Transform[T any](items []T, f func(T) T, workers int) []T {
+	tasks := make([]Tasker, len(items))
+	for i := range items {
+		tasks[i] = &mapTask[T, T]{f: f, in: items[i], out: &items[i]}
+	}
+	runHelper(tasks, workers)
+	return items
+}
+
+// ForEach calls f with the index and value of every item, in
+// parallel using workers workers as Map does. Unlike helpers that
+// return outputs it executes items as Run does: signals stop it and
+// panics of f are returned, in *TaskError whose Index is the one of
+// the item, rather than propagated.
+func ForEach[T any](items []T, f func(i int, v T), workers int) error {
+	return ForEachContext(context.Background(), items, f, workers)
+}
+
+// ForEachContext is like ForEach but stops calling f
+// as soon as ctx is done, as RunContext does.
+func ForEachContext[T any](ctx context.Context, items []T, f func(i int, v T), workers int) error {
+	tasks := make([]Tasker, len(items))
+	for i, v := range items {
+		tasks[i] = TaskFunc(func() { f(i, v) })
+	}
+	return run(ctx, tasks, workersOptions(workers))
+}
+
+// MapReduce applies mapper to every input in parallel as Map does,
+// then folds outputs with reducer starting from initial. Reduce runs
+// serially in the order of inputs, reducer needs no locking.
+// A panic of mapper is propagated.
+func MapReduce[In, Out, Acc any](inputs []In, mapper func(In) Out, reducer func(Acc, Out) Acc, initial Acc) Acc {
+	acc := initial
+	for _, out := range Map(inputs, mapper, 0) {
+		acc = reducer(acc, out)
+	}
+	return acc
+}
+
+// ConcurrentReduce folds inputs partitioning them in contiguous
+// chunks, one per worker as Chunked does: every chunk is accumulated
+// in parallel starting from its own identity(), then partial results
+// are merged pairwise, every level of the merge in parallel. merge must
+// be associative, partial results are merged in the order of inputs.
+// Unlike MapReduce no serial fold of every output limits scaling and
+// no locking is needed. A panic of accumulate or merge is propagated.
+func ConcurrentReduce[In, Acc any](inputs []In, identity func() Acc, accumulate func(Acc, In) Acc, merge func(Acc, Acc) Acc, workers int) Acc {
+	if len(inputs) == 0 {
+		return identity()
+	}
+	n := min(workersOptions(workers).workers(), len(inputs))
+	accs := make([]Acc, n)
+	tasks := make([]Tasker, n)
+	for i := range tasks {
+		chunk := inputs[i*len(inputs)/n : (i+1)*len(inputs)/n]
+		tasks[i] = TaskFunc(func() {
+			acc := identity()
+			for _, in := range chunk {
+				acc = accumulate(acc, in)
+			}
+			accs[i] = acc
+		})
+	}
+	runHelper(tasks, workers)
+	for len(accs) > 1 {
+		merged := make([]Acc, (len(accs)+1)/2)
+		tasks = tasks[:0]
+		for i := 0; i+1 < len(accs); i += 2 {
+			tasks = append(tasks, TaskFunc(func() { merged[i/2] = merge(accs[i], accs[i+1]) }))
+		}
+		if len(accs)%2 == 1 {
+			merged[len(merged)-1] = accs[len(accs)-1]
+		}
+		runHelper(tasks, workers)
+		accs = merged
+	}
+	return accs[0]
+}
+
+// MapErr is like Map but f receives a context, done once ctx is or
+// f failed. Outputs of inputs not processed are zero values.
+// The first error, or the one wrapping ctx.Err(), is returned.
+func MapErr[In, Out any](ctx context.Context, inputs []In, f func(context.Context, In) (Out, error), workers int) ([]Out, error) {
+	outputs := make([]Out, len(inputs))
+	tasks := make([]Tasker, len(inputs))
+	for i := range inputs {
+		tasks[i] = &mapErrTask[In, Out]{f: f, in: inputs[i], out: &outputs[i]}
+	}
+	opts := workersOptions(workers)
+	opts.FailFast = true
+	return outputs, runTasks(ctx, tasks, opts).err
+}
+
+// mapErrTask is executed by workers with executeCtx.
+type mapErrTask[In, Out any] struct {
+	f   func(context.Context, In) (Out, error)
+	in  In
+	out *Out
+}
+
+func (m *mapErrTask[In, Out]) Execute() {
+	m.executeCtx(context.Background())
+}
+
+func (m *mapErrTask[In, Out]) executeCtx(ctx context.Context) (err error) {
+	*m.out, err = m.f(ctx, m.in)
+	return err
+}
+
+type mapTask[In, Out any] struct {
+	f   func(In) Out
+	in  In
+	out *Out
+}
+
+func (m *mapTask[In, Out]) Execute() {
+	*m.out = m.f(m.in)
+}
+
+// workersOptions returns Options for helpers that accept
+// a number of workers, zero or less meaning the default.
+func workersOptions(workers int) Options {
+	if workers < 0 {
+		workers = 0
+	}
+	return Options{Workers: workers}
+}
+
+// runHelper executes tasks for helpers that return no error, e.g.
+// Map, as a plain loop would: signals are not trapped and the
+// first panic of a Tasker is propagated as a *PanicError, rather
+// than silently leaving a zero output.
+func runHelper(tasks []Tasker, workers int) {
+	opts := workersOptions(workers)
+	opts.Signals = []os.Signal{}
+	opts.ErrorMode = ErrFirst
+	err := RunWith(tasks, opts)
+	if err == nil {
+		return
+	}
+	var pe *PanicError
+	if errors.As(err, &pe) {
+		panic(pe)
+	}
+	panic(err)
+}
+
+// Chunked partitions items in about workers contiguous chunks,
+// zero or less meaning the package default, returning a Tasker
+// for every chunk that calls f on it.
+func Chunked[T any](items []T, workers int, f func([]T)) []Tasker {
+	n := workersOptions(workers).workers()
+	if n > len(items) {
+		n = len(items)
+	}
+	tasks := make([]Tasker, 0, n)
+	for i := 0; i < n; i++ {
+		// Chunk lengths differ by one at most, the
+		// remainder is spread evenly among them.
+		start := i * len(items) / n
+		stop := (i + 1) * len(items) / n
+		tasks = append(tasks, chunkTask[T]{f: f, items: items[start:stop]})
+	}
+	return tasks
+}
+
+// BalancedChunks is like Chunked but partitions items in contiguous
+// chunks of about the same total cost, rather than the same length,
+// so that no worker remains idle sooner when items have uneven costs.
+// build returns the Tasker for every chunk.
+func BalancedChunks[T any](items []T, workers int, cost func(T) int, build func([]T) Tasker) []Tasker {
+	n := workersOptions(workers).workers()
+	if n > len(items) {
+		n = len(items)
+	}
+	costs := make([]int, len(items))
+	var remaining int
+	for i, it := range items {
+		costs[i] = cost(it)
+		remaining += costs[i]
+	}
+	tasks := make([]Tasker, 0, n)
+	var start int
+	for chunks := n; chunks > 0; chunks-- {
+		stop := len(items)
+		if chunks > 1 {
+			target := remaining / chunks
+			var sum int
+			stop = start
+			// Every chunk gets at least an item.
+			for stop < len(items)-(chunks-1) && (stop == start || sum < target) {
+				sum += costs[stop]
+				stop++
+			}
+			remaining -= sum
+		}
+		tasks = append(tasks, build(items[start:stop]))
+		start = stop
+	}
+	return tasks
+}
+
+type chunkTask[T any] struct {
+	f     func([]T)
+	items []T
+}
+
+func (c chunkTask[T]) Execute() {
+	c.f(c.items)
+}
@@ -0,0 +1,301 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRunG(t *testing.T) {
+	tasks := make([]*dummy, 1e2)
+	for i := range tasks {
+		tasks[i] = &dummy{}
+	}
+	if err := RunG(tasks); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range tasks {
+		if !e.done {
+			t.Fatal("task not executed")
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	inputs := make([]uint64, 1e3)
+	for i := range inputs {
+		inputs[i] = uint64(i)
+	}
+	for _, workers := range []int{0, 1, 3} {
+		outputs := Map(inputs, isPrime, workers)
+		if len(outputs) != len(inputs) {
+			t.Fatalf("expected %d outputs, got %d", len(inputs), len(outputs))
+		}
+		for i, o := range outputs {
+			if o != isPrime(inputs[i]) {
+				t.Fatalf("%d workers: wrong output at index %d", workers, i)
+			}
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	inputs := []string{"1", "22", "333", "4444"}
+	for _, workers := range []int{0, 1, 3} {
+		outputs := Pipe(inputs, func(s string) int { return len(s) }, func(n int) bool { return n%2 == 0 }, workers)
+		want := []bool{false, true, false, true}
+		if !reflect.DeepEqual(outputs, want) {
+			t.Fatalf("%d workers: expected %v, got %v", workers, want, outputs)
+		}
+	}
+	defer func() {
+		if _, ok := recover().(*PanicError); !ok {
+			t.Fatal("expected a *PanicError")
+		}
+	}()
+	Pipe(inputs, func(s string) int { return len(s) }, func(n int) int { return 1 / (n - 3) }, 2)
+}
+
+func TestMap_panic(t *testing.T) {
+	defer func() {
+		pe, ok := recover().(*PanicError)
+		if !ok || pe.Value != "boom" {
+			t.Fatal("expected a *PanicError, got", pe)
+		}
+	}()
+	Map([]int{1, 2, 3}, func(i int) int {
+		if i == 2 {
+			panic("boom")
+		}
+		return i
+	}, 2)
+	t.Fatal("Map did not propagate the panic")
+}
+
+func TestTransform(t *testing.T) {
+	items := make([]int, 1e3)
+	for i := range items {
+		items[i] = i
+	}
+	for _, workers := range []int{0, 1, 3} {
+		out := Transform(items, func(i int) int { return i + 1 }, workers)
+		if &out[0] != &items[0] {
+			t.Fatal("items not transformed in place")
+		}
+	}
+	for i, e := range items {
+		if e != i+3 {
+			t.Fatalf("expected %d at index %d, got %d", i+3, i, e)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	for _, n := range testWorkers {
+		items := make([]int, 1e2)
+		for i := range items {
+			items[i] = i * 2
+		}
+		seen := make([]int, len(items))
+		err := ForEach(items, func(i, v int) { seen[i] = v }, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(seen, items) {
+			t.Fatalf("%d workers: unexpected values %v", n, seen)
+		}
+	}
+}
+
+func TestForEach_panic(t *testing.T) {
+	err := ForEach([]int{0, 1, 2}, func(i, _ int) {
+		if i == 1 {
+			panic("boom")
+		}
+	}, 2)
+	var te *TaskError
+	var pe *PanicError
+	if !errors.As(err, &te) || te.Index != 1 || !errors.As(err, &pe) {
+		t.Fatal("expected the panic of the second item, got", err)
+	}
+}
+
+func TestForEachContext_done(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := ForEachContext(ctx, make([]int, 1e2), func(int, int) {}, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
+
+func TestMapReduce(t *testing.T) {
+	inputs := make([]uint64, 1e3)
+	for i := range inputs {
+		inputs[i] = uint64(i)
+	}
+	var expected int
+	for _, e := range inputs {
+		if isPrime(e) {
+			expected++
+		}
+	}
+	primes := MapReduce(inputs, isPrime, func(acc int, prime bool) int {
+		if prime {
+			acc++
+		}
+		return acc
+	}, 0)
+	if primes != expected {
+		t.Fatalf("expected %d primes, got %d", expected, primes)
+	}
+	// Reduce must follow inputs order.
+	order := MapReduce(inputs[:10], func(i uint64) uint64 { return i }, func(acc []uint64, i uint64) []uint64 {
+		return append(acc, i)
+	}, nil)
+	for i, e := range order {
+		if e != uint64(i) {
+			t.Fatalf("expected %d at index %d, got %d", i, i, e)
+		}
+	}
+}
+
+func TestConcurrentReduce(t *testing.T) {
+	inputs := make([]uint64, 1e3)
+	var expected int
+	for i := range inputs {
+		inputs[i] = uint64(i)
+		if isPrime(inputs[i]) {
+			expected++
+		}
+	}
+	count := func(acc int, i uint64) int {
+		if isPrime(i) {
+			acc++
+		}
+		return acc
+	}
+	sum := func(a, b int) int { return a + b }
+	for _, w := range []int{1, 2, 3, 7, 2e3} {
+		if n := ConcurrentReduce(inputs, func() int { return 0 }, count, sum, w); n != expected {
+			t.Fatalf("workers=%d: expected %d primes, got %d", w, expected, n)
+		}
+		// Partial results are merged in inputs order.
+		order := ConcurrentReduce(inputs[:10], func() []uint64 { return nil }, func(acc []uint64, i uint64) []uint64 {
+			return append(acc, i)
+		}, func(a, b []uint64) []uint64 { return append(a, b...) }, w)
+		for i, e := range order {
+			if e != uint64(i) {
+				t.Fatalf("workers=%d: expected %d at index %d, got %d", w, i, i, e)
+			}
+		}
+	}
+	if n := ConcurrentReduce(nil, func() int { return 42 }, count, sum, 0); n != 42 {
+		t.Fatal("expected identity for no inputs, got", n)
+	}
+}
+
+func TestChunked(t *testing.T) {
+	for _, tc := range []struct{ items, workers, chunks int }{
+		{0, 4, 0},
+		{3, 4, 3},
+		{10, 4, 4},
+		{1e3, 7, 7},
+	} {
+		items := make([]int32, tc.items)
+		tasks := Chunked(items, tc.workers, func(chunk []int32) {
+			for i := range chunk {
+				chunk[i]++
+			}
+		})
+		if len(tasks) != tc.chunks {
+			t.Fatalf("%d items on %d workers: expected %d chunks, got %d", tc.items, tc.workers, tc.chunks, len(tasks))
+		}
+		if err := Run(tasks); err != nil {
+			t.Fatal(err)
+		}
+		for i, e := range items {
+			if e != 1 {
+				t.Fatalf("item %d visited %d times", i, e)
+			}
+		}
+	}
+}
+
+func TestBalancedChunks(t *testing.T) {
+	items := make([]int, 1e3)
+	var total int
+	for i := range items {
+		items[i] = i
+		total += i
+	}
+	var costs []int
+	seen := make([]int, len(items))
+	tasks := BalancedChunks(items, 4, func(i int) int { return i }, func(chunk []int) Tasker {
+		var cost int
+		for _, i := range chunk {
+			seen[i]++
+			cost += i
+		}
+		costs = append(costs, cost)
+		return &dummy{}
+	})
+	if len(tasks) != 4 {
+		t.Fatalf("expected 4 chunks, got %d", len(tasks))
+	}
+	for i, n := range seen {
+		if n != 1 {
+			t.Fatalf("item %d in %d chunks", i, n)
+		}
+	}
+	for _, c := range costs {
+		if d := c - total/4; d > total/50 || d < -total/50 {
+			t.Fatalf("unbalanced chunk costs %v", costs)
+		}
+	}
+	if tasks := BalancedChunks(items[:2], 4, func(int) int { return 1 }, func([]int) Tasker { return &dummy{} }); len(tasks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d", len(tasks))
+	}
+}
+
+func TestMapErr(t *testing.T) {
+	inputs := make([]int, 1e2)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	double := func(_ context.Context, i int) (int, error) { return 2 * i, nil }
+	outputs, err := MapErr(context.Background(), inputs, double, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, o := range outputs {
+		if o != 2*i {
+			t.Fatalf("expected %d at index %d, got %d", 2*i, i, o)
+		}
+	}
+	errNegative := errors.New("negative input")
+	inputs[10] = -1
+	check := func(ctx context.Context, i int) (int, error) {
+		if i < 0 {
+			return 0, errNegative
+		}
+		return i, ctx.Err()
+	}
+	outputs, err = MapErr(context.Background(), inputs, check, 1)
+	if err != errNegative {
+		t.Fatal("expected errNegative, got", err)
+	}
+	if outputs[5] != 5 || outputs[99] != 0 {
+		t.Fatal("unexpected partial outputs", outputs)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := MapErr(ctx, inputs, double, 2); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "sync"
+
+// GoTasker is a Tasker that starts goroutines of its own, see
+// Options.GoroutineLimit. Runs that support it call ExecuteGo in
+// place of Execute, others call Execute.
+type GoTasker interface {
+	Tasker
+	// ExecuteGo executes the Tasker starting goroutines only by
+	// calling spawn, which runs f in a new goroutine if the limit
+	// allows it, in the calling one otherwise. The Tasker is done
+	// once ExecuteGo and all functions passed to spawn returned.
+	ExecuteGo(spawn func(f func()))
+}
+
+// goLimit bounds goroutines started by GoTaskers of a run,
+// a nil *goLimit does not bound them.
+type goLimit struct {
+	slots chan struct{}
+}
+
+// newGoLimit returns a goLimit allowing limit goroutines, workers
+// included, or nil if limit is zero. With no slot left to GoTaskers
+// they run functions passed to spawn in their own goroutine.
+func newGoLimit(limit, workers int) *goLimit {
+	if limit == 0 {
+		return nil
+	}
+	return &goLimit{make(chan struct{}, max(limit-workers, 0))}
+}
+
+// acquire reports whether a goroutine can be started,
+// it never blocks: waiting for a slot could deadlock
+// Taskers waiting for their own goroutines.
+func (l *goLimit) acquire() bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (l *goLimit) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// wrap returns j ready to be executed, GoTaskers
+// start goroutines within l.
+func (l *goLimit) wrap(j indexedTask) indexedTask {
+	if g, ok := j.Tasker.(GoTasker); ok {
+		j.Tasker = &goTask{GoTasker: g, limit: l}
+	}
+	return j
+}
+
+// goTask adapts a GoTasker to Tasker, the first panic of
+// functions passed to spawn is its error.
+type goTask struct {
+	GoTasker
+	limit *goLimit
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	err   error
+}
+
+func (g *goTask) Execute() {
+	g.executeErr()
+}
+
+func (g *goTask) executeErr() (err error) {
+	defer func() {
+		g.wg.Wait()
+		if err == nil {
+			err = g.err
+		}
+	}()
+	g.GoTasker.ExecuteGo(g.spawn)
+	return nil
+}
+
+func (g *goTask) spawn(f func()) {
+	if !g.limit.acquire() {
+		g.run(f)
+		return
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.limit.release()
+		g.run(f)
+	}()
+}
+
+// run calls f recording its panic, if any.
+func (g *goTask) run(f func()) {
+	if err := execute(TaskFunc(f)); err != nil {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.err == nil {
+			g.err = err
+		}
+	}
+}
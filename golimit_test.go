@@ -0,0 +1,91 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fanOut spawns n functions, tracking how many run at once
+// in the Tasker goroutine and in spawned ones.
+type fanOut struct {
+	n             int
+	running, peak *int32
+	panics        bool
+}
+
+func (f *fanOut) track() {
+	n := atomic.AddInt32(f.running, 1)
+	for {
+		m := atomic.LoadInt32(f.peak)
+		if n <= m || atomic.CompareAndSwapInt32(f.peak, m, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	atomic.AddInt32(f.running, -1)
+}
+
+func (f *fanOut) Execute() {
+	f.ExecuteGo(func(g func()) { go g() })
+}
+
+func (f *fanOut) ExecuteGo(spawn func(func())) {
+	for i := 0; i < f.n; i++ {
+		spawn(func() {
+			if f.panics {
+				panic("child")
+			}
+			f.track()
+		})
+	}
+}
+
+func TestRunWith_goroutineLimit(t *testing.T) {
+	for _, w := range []int{1, 2} {
+		var running, peak int32
+		tasks := make([]Tasker, 10)
+		for i := range tasks {
+			tasks[i] = &fanOut{n: 10, running: &running, peak: &peak}
+		}
+		// Workers count toward the limit, they do not run
+		// functions themselves while their children do.
+		if err := RunWith(tasks, Options{Workers: w, GoroutineLimit: w + 3}); err != nil {
+			t.Fatal(err)
+		}
+		if peak > int32(w+3) {
+			t.Fatalf("workers=%d: expected at most %d goroutines, got %d", w, w+3, peak)
+		}
+		if running != 0 {
+			t.Fatal("run returned before spawned goroutines")
+		}
+	}
+	var running, peak int32
+	err := RunWith([]Tasker{&fanOut{n: 3, running: &running, peak: &peak, panics: true}}, Options{GoroutineLimit: 1})
+	var p *PanicError
+	if !errors.As(err, &p) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	if err := RunWith(nil, Options{GoroutineLimit: -1}); err != ErrInvalidResourceLimit {
+		t.Fatal("expected ErrInvalidResourceLimit, got", err)
+	}
+}
+
+func TestRunWithStats_goroutineLimitWorkers(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &counter{}
+	}
+	s, err := RunWithStats(tasks, Options{Workers: 4, GoroutineLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Workers) != 2 {
+		t.Fatalf("expected workers capped at 2, got %d", len(s.Workers))
+	}
+}
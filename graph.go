@@ -0,0 +1,151 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrCycle says that dependencies of a graph form a cycle.
+var ErrCycle = errors.New("dependency cycle")
+
+// ErrUnknownDependency says that a dependency is not in the graph.
+var ErrUnknownDependency = errors.New("unknown dependency")
+
+// ErrDependencyFailed says that a Tasker has not been
+// executed because one of its dependencies failed.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+// RunGraph executes tasks on workers as Run does, starting a Tasker
+// only once all Taskers it depends on, listed by name in deps, are done.
+// Independent Taskers are executed in parallel. If a Tasker fails its
+// dependents are not executed and yield an error wrapping
+// ErrDependencyFailed. Nothing is executed if deps reference unknown
+// Taskers or form a cycle. Signals are not trapped.
+func RunGraph(tasks map[string]Tasker, deps map[string][]string) error {
+	g, err := newGraph(tasks, deps)
+	if err != nil {
+		return err
+	}
+	n := workers()
+	if n > len(tasks) {
+		n = len(tasks)
+	}
+	ready := make(chan string, len(tasks))
+	done := make(chan graphDone, len(tasks))
+	for i := 0; i < n; i++ {
+		go func() {
+			for name := range ready {
+				done <- graphDone{name, execute(tasks[name])}
+			}
+		}()
+	}
+	defer close(ready)
+	for _, name := range g.roots() {
+		ready <- name
+	}
+	var errs errCollector
+	for completed := 0; completed < len(tasks); completed++ {
+		d := <-done
+		if d.err != nil {
+			errs.add(&TaskError{Index: -1, Name: d.name, Task: tasks[d.name], Err: d.err})
+		}
+		for _, next := range g.dependents[d.name] {
+			if d.err != nil {
+				g.failed[next] = true
+			}
+			g.pending[next]--
+			if g.pending[next] > 0 {
+				continue
+			}
+			if g.failed[next] {
+				// Skipped Taskers complete immediately.
+				done <- graphDone{next, ErrDependencyFailed}
+				continue
+			}
+			ready <- next
+		}
+	}
+	return errs.join(nil)
+}
+
+// graphDone is sent for every completed Tasker of a graph.
+type graphDone struct {
+	name string
+	err  error
+}
+
+// graph tracks readiness of Taskers.
+type graph struct {
+	// pending is the number of dependencies not done yet.
+	pending map[string]int
+	// dependents are Taskers that depend on a given one.
+	dependents map[string][]string
+	// failed are Taskers with a failed dependency.
+	failed map[string]bool
+}
+
+func newGraph(tasks map[string]Tasker, deps map[string][]string) (*graph, error) {
+	g := &graph{
+		pending:    make(map[string]int, len(tasks)),
+		dependents: make(map[string][]string),
+		failed:     make(map[string]bool),
+	}
+	for name := range tasks {
+		g.pending[name] = 0
+	}
+	for name, dd := range deps {
+		if _, ok := tasks[name]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownDependency, name)
+		}
+		for _, d := range dd {
+			if _, ok := tasks[d]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownDependency, d)
+			}
+			g.pending[name]++
+			g.dependents[d] = append(g.dependents[d], name)
+		}
+	}
+	if g.cyclic() {
+		return nil, ErrCycle
+	}
+	return g, nil
+}
+
+// roots returns Taskers without dependencies sorted by name.
+func (g *graph) roots() []string {
+	var roots []string
+	for name, n := range g.pending {
+		if n == 0 {
+			roots = append(roots, name)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// cyclic tells if some Tasker can never become ready.
+func (g *graph) cyclic() bool {
+	pending := make(map[string]int, len(g.pending))
+	for name, n := range g.pending {
+		pending[name] = n
+	}
+	queue := g.roots()
+	visited := 0
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, next := range g.dependents[name] {
+			pending[next]--
+			if pending[next] == 0 {
+				queue = append(queue, next)
+			}
+		}
+	}
+	return visited < len(g.pending)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// node records when it's executed.
+type node struct {
+	name  string
+	mu    *sync.Mutex
+	order *[]string
+}
+
+func (n node) Execute() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	*n.order = append(*n.order, n.name)
+}
+
+func TestRunGraph(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	tasks := make(map[string]Tasker)
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		tasks[name] = node{name, &mu, &order}
+	}
+	deps := map[string][]string{
+		"c": {"a", "b"},
+		"d": {"c"},
+		"e": {"a"},
+	}
+	if err := RunGraph(tasks, deps); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != len(tasks) {
+		t.Fatalf("expected %d tasks executed, got %v", len(tasks), order)
+	}
+	position := make(map[string]int)
+	for i, name := range order {
+		position[name] = i
+	}
+	for name, dd := range deps {
+		for _, d := range dd {
+			if position[d] > position[name] {
+				t.Fatalf("%s executed before its dependency %s: %v", name, d, order)
+			}
+		}
+	}
+}
+
+func TestRunGraph_failure(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	tasks := map[string]Tasker{
+		"a": panicker{},
+		"b": node{"b", &mu, &order},
+		"c": node{"c", &mu, &order},
+		"d": node{"d", &mu, &order},
+	}
+	err := RunGraph(tasks, map[string][]string{"b": {"a"}, "c": {"b"}})
+	var p *PanicError
+	if !errors.As(err, &p) || !errors.Is(err, ErrDependencyFailed) {
+		t.Fatal("expected a *PanicError and ErrDependencyFailed, got", err)
+	}
+	var te *TaskError
+	if !errors.As(err, &te) || te.Name != "a" || te.Task != tasks["a"] {
+		t.Fatal("expected a *TaskError for a, got", err)
+	}
+	if len(order) != 1 || order[0] != "d" {
+		t.Fatal("expected only d executed, got", order)
+	}
+}
+
+func TestRunGraph_invalid(t *testing.T) {
+	tasks := map[string]Tasker{"a": &dummy{}, "b": &dummy{}, "c": &dummy{}}
+	err := RunGraph(tasks, map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}})
+	if err != ErrCycle {
+		t.Fatal("expected ErrCycle, got", err)
+	}
+	err = RunGraph(tasks, map[string][]string{"a": {"z"}})
+	if !errors.Is(err, ErrUnknownDependency) {
+		t.Fatal("expected ErrUnknownDependency, got", err)
+	}
+	for _, e := range tasks {
+		if e.(*dummy).done {
+			t.Fatal("task executed in an invalid graph")
+		}
+	}
+	if err := RunGraph(nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
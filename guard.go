@@ -0,0 +1,30 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrTooFast says that a batch completed faster than expected,
+// probably without doing any real work.
+var ErrTooFast = errors.New("tasks completed too fast, they might have done nothing")
+
+// RunMinDuration executes jobs like Run but returns ErrTooFast if the
+// whole batch takes less than minDuration. It's an heuristic safety net
+// against Taskers that silently do nothing, a typical case being
+// Execute implemented on a value receiver whose results are lost.
+// ErrTooFast is only a warning: all jobs have been executed.
+func RunMinDuration(jobs []Tasker, minDuration time.Duration) error {
+	pre := time.Now()
+	if err := Run(jobs); err != nil {
+		return err
+	}
+	if time.Since(pre) < minDuration {
+		return ErrTooFast
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+type nop struct {
+	done bool
+}
+
+// Execute on a value receiver: assignment is lost.
+func (n nop) Execute() { n.done = true }
+
+func TestRunMinDuration(t *testing.T) {
+	nops := make([]Tasker, 1e1)
+	for i := range nops {
+		nops[i] = Tasker(nop{})
+	}
+	if err := RunMinDuration(nops, 10*time.Millisecond); err != ErrTooFast {
+		t.Fatal("expected ErrTooFast, got", err)
+	}
+	work := []Tasker{&sleeper{d: 20 * time.Millisecond}}
+	if err := RunMinDuration(work, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "time"
+
+// RunHeartbeat executes jobs like Run calling beat every d while
+// the run is in progress, regardless of tasks completions.
+// It is useful to inform watchdogs that a batch of long running
+// Taskers is still alive. beat is never called after RunHeartbeat
+// has returned. d must be positive, otherwise ErrInvalidTimeout
+// is returned and jobs are not executed.
+func RunHeartbeat(jobs []Tasker, d time.Duration, beat func()) error {
+	if d <= 0 {
+		return ErrInvalidTimeout
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(d)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				beat()
+			case <-done:
+				return
+			}
+		}
+	}()
+	err := Run(jobs)
+	close(done)
+	<-stopped
+	return err
+}
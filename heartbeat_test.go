@@ -0,0 +1,38 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+type sleeper struct {
+	d time.Duration
+}
+
+func (s *sleeper) Execute() { time.Sleep(s.d) }
+
+func TestRunHeartbeat(t *testing.T) {
+	tasks := []Tasker{&sleeper{d: 100 * time.Millisecond}}
+	var beats int
+	err := RunHeartbeat(tasks, 10*time.Millisecond, func() { beats++ })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if beats < 5 {
+		t.Fatalf("expected at least 5 heartbeats, got %d", beats)
+	}
+	after := beats
+	time.Sleep(30 * time.Millisecond)
+	if beats != after {
+		t.Fatal("heartbeat fired after run completed")
+	}
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err := RunHeartbeat(tasks, d, func() {}); err != ErrInvalidTimeout {
+			t.Fatalf("expected ErrInvalidTimeout for %s, got %v", d, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+// Histogram returns how many times every item appears in in.
+// in is split in one contiguous chunk per worker, every worker
+// counts its own chunk in a private map so that no locking is
+// needed, partial counts are merged at the end.
+// Signals are not trapped.
+func Histogram[T comparable](in []T) map[T]int {
+	n := workers()
+	if n > len(in) {
+		n = len(in)
+	}
+	tasks := make([]Tasker, n)
+	partials := make([]*countTask[T], n)
+	for i := range tasks {
+		c := &countTask[T]{items: in[i*len(in)/n : (i+1)*len(in)/n]}
+		partials[i] = c
+		tasks[i] = c
+	}
+	runHelper(tasks, 0)
+	counts := make(map[T]int)
+	for _, p := range partials {
+		for k, v := range p.counts {
+			counts[k] += v
+		}
+	}
+	return counts
+}
+
+type countTask[T comparable] struct {
+	items  []T
+	counts map[T]int
+}
+
+func (c *countTask[T]) Execute() {
+	c.counts = make(map[T]int)
+	for _, item := range c.items {
+		c.counts[item]++
+	}
+}
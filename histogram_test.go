@@ -0,0 +1,44 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func randomInts(n, max int) []int {
+	rng := rand.New(rand.NewSource(1))
+	in := make([]int, n)
+	for i := range in {
+		in[i] = rng.Intn(max)
+	}
+	return in
+}
+
+func TestHistogram(t *testing.T) {
+	in := randomInts(1e4, 100)
+	expected := make(map[int]int)
+	for _, v := range in {
+		expected[v]++
+	}
+	if got := Histogram(in); !reflect.DeepEqual(got, expected) {
+		t.Fatal("histogram differs from serial count")
+	}
+	if got := Histogram([]string{}); len(got) != 0 {
+		t.Fatal("expected empty histogram")
+	}
+}
+
+// BenchmarkHistogram_highCardinality measures merge overhead
+// when almost every item is distinct.
+func BenchmarkHistogram_highCardinality(b *testing.B) {
+	in := randomInts(1e5, 1e9)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Histogram(in)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInvalidTarget says that an in-flight target is less than 1.
+var ErrInvalidTarget = errors.New("in-flight target must be at least 1")
+
+// RunInFlight executes jobs keeping target of them running at any
+// time, rather than using one worker per core. A new Tasker is started,
+// on its own goroutine, as soon as a running one completes so that
+// in-flight count stays at target regardless of how long single
+// Taskers take. It suits Taskers that block, like IO bound ones.
+func RunInFlight(jobs []Tasker, target int) error {
+	if target < 1 {
+		return ErrInvalidTarget
+	}
+	slots := make(chan struct{}, target)
+	var panics errCollector
+	var wg sync.WaitGroup
+	for i, j := range jobs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(i int, j Tasker) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			if err := execute(j); err != nil {
+				panics.add(&TaskError{Index: i, Task: j, Err: err})
+			}
+		}(i, j)
+	}
+	wg.Wait()
+	return panics.join(nil)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type inFlightTask struct {
+	d        time.Duration
+	inFlight *int32
+	max      *int32
+}
+
+func (f *inFlightTask) Execute() {
+	n := atomic.AddInt32(f.inFlight, 1)
+	for {
+		m := atomic.LoadInt32(f.max)
+		if n <= m || atomic.CompareAndSwapInt32(f.max, m, n) {
+			break
+		}
+	}
+	time.Sleep(f.d)
+	atomic.AddInt32(f.inFlight, -1)
+}
+
+func TestRunInFlight(t *testing.T) {
+	const target = 5
+	var inFlight, max int32
+	tasks := make([]Tasker, 50)
+	for i := range tasks {
+		// Variable durations, from 1ms to 10ms.
+		d := time.Duration(i%10+1) * time.Millisecond
+		tasks[i] = Tasker(&inFlightTask{d: d, inFlight: &inFlight, max: &max})
+	}
+	stop := make(chan struct{})
+	samples := make(chan int32)
+	go func() {
+		var sum, n int32
+		for {
+			select {
+			case <-stop:
+				if n == 0 {
+					n = 1
+				}
+				samples <- sum / n
+				return
+			case <-time.After(time.Millisecond):
+				if v := atomic.LoadInt32(&inFlight); v > 0 {
+					sum += v
+					n++
+				}
+			}
+		}
+	}()
+	if err := RunInFlight(tasks, target); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	if avg := <-samples; avg < target-1 {
+		t.Errorf("average in-flight %d too far from target %d", avg, target)
+	}
+	if max != target {
+		t.Errorf("expected max in-flight %d, got %d", target, max)
+	}
+	if err := RunInFlight(tasks, 0); err != ErrInvalidTarget {
+		t.Fatal("expected ErrInvalidTarget, got", err)
+	}
+}
+
+func TestRunInFlight_taskError(t *testing.T) {
+	err := RunInFlight([]Tasker{&dummy{}, panicker{}}, 2)
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected a *TaskError for task 1, got", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket, holding at most one token,
+// shared by workers of a run.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	// next is when next token will be available.
+	next time.Time
+}
+
+// newLimiter returns a limiter allowing rate events per second,
+// or nil if rate is zero.
+func newLimiter(rate float64) *limiter {
+	if rate == 0 {
+		return nil
+	}
+	return &limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// wait blocks until an event is allowed.
+// It's a no-op on a nil limiter.
+func (l *limiter) wait() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	// Reserve the token making room for the next one.
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+	time.Sleep(at.Sub(now))
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+type startRecorder struct {
+	mu     *sync.Mutex
+	starts *[]time.Time
+}
+
+func (s startRecorder) Execute() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	*s.starts = append(*s.starts, time.Now())
+}
+
+func TestRunWith_rate(t *testing.T) {
+	const rate = 20
+	var mu sync.Mutex
+	var starts []time.Time
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = startRecorder{&mu, &starts}
+	}
+	if err := RunWith(tasks, Options{Workers: 4, RatePerSecond: rate}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	// 10 starts need at least 9 intervals, allow some scheduling slack.
+	span := starts[len(starts)-1].Sub(starts[0])
+	if min := 9 * time.Second / rate * 8 / 10; span < min {
+		t.Fatalf("10 tasks started within %s, expected at least %s", span, min)
+	}
+	if err := RunWith(tasks, Options{RatePerSecond: -1}); err != ErrInvalidRate {
+		t.Fatal("expected ErrInvalidRate, got", err)
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"time"
+)
+
+// defaultGateInterval is how often a closed
+// LoadGate is polled if no interval is set.
+const defaultGateInterval = 100 * time.Millisecond
+
+// waitGate blocks while opts.LoadGate returns false, polling it every
+// opts.LoadGateInterval. It reports false if ctx is done first.
+func waitGate(ctx context.Context, opts Options) bool {
+	if opts.LoadGate == nil {
+		return true
+	}
+	interval := opts.LoadGateInterval
+	if interval == 0 {
+		interval = defaultGateInterval
+	}
+	for !opts.LoadGate() {
+		if !sleep(ctx, interval) {
+			return false
+		}
+	}
+	return ctx.Err() == nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWith_loadGate(t *testing.T) {
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			var open atomic.Bool
+			c := &counter{}
+			jobs := []Tasker{c, c, c, c}
+			done := make(chan error)
+			go func() {
+				done <- RunWith(jobs, Options{
+					Workers:          n,
+					LoadGate:         open.Load,
+					LoadGateInterval: time.Millisecond,
+				})
+			}()
+			time.Sleep(20 * time.Millisecond)
+			if e := atomic.LoadInt32(&c.n); e != 0 {
+				t.Fatalf("%d tasks started with a closed gate", e)
+			}
+			open.Store(true)
+			if err := <-done; err != nil {
+				t.Fatal(err)
+			}
+			if c.n != 4 {
+				t.Fatalf("expected 4 executions, got %d", c.n)
+			}
+		})
+	}
+}
+
+func TestRunContext_loadGateClosed(t *testing.T) {
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			tasks := []Tasker{&cancelable{}, &cancelable{}}
+			opts := Options{Workers: n, LoadGate: func() bool { return false }}
+			err := run(ctx, tasks, opts)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatal("expected context.DeadlineExceeded, got", err)
+			}
+			for i, e := range tasks {
+				if c := e.(*cancelable); c.executed != 0 || c.canceled != 1 {
+					t.Fatalf("task %d executed %d times and canceled %d times", i, c.executed, c.canceled)
+				}
+			}
+			if err := RunWith(tasks, Options{LoadGateInterval: -1}); err != ErrInvalidTimeout {
+				t.Fatal("expected ErrInvalidTimeout, got", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+// Logger receives structured events of a run as a message
+// followed by alternating keys and values. *slog.Logger
+// satisfies it, other backends plug in with an adapter.
+// Runs with a nil Logger log nothing.
+type Logger interface {
+	Info(msg string, args ...any)
+}
+
+// logEvent sends an event to l, if not nil.
+func logEvent(l Logger, msg string, args ...any) {
+	if l != nil {
+		l.Info(msg, args...)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventLogger counts events by message.
+type eventLogger struct {
+	mu     sync.Mutex
+	events map[string]int
+}
+
+func (l *eventLogger) Info(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(args)%2 != 0 {
+		panic("odd number of arguments")
+	}
+	l.events[msg]++
+}
+
+func TestRunWith_logger(t *testing.T) {
+	l := &eventLogger{events: make(map[string]int)}
+	initTests()
+	if err := RunWith(testCases, Options{Workers: 3, Logger: l}); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int{
+		"parallel: worker started": 3,
+		"parallel: worker done":    3,
+		"parallel: queue closed":   1,
+	}
+	for msg, n := range expected {
+		if l.events[msg] != n {
+			t.Fatalf("expected %d %q events, got %d", n, msg, l.events[msg])
+		}
+	}
+	if l.events["parallel: premature end"] != 0 {
+		t.Fatal("unexpected premature end event")
+	}
+	if err := RunWith([]Tasker{panicker{}}, Options{FailFast: true, Logger: l}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if l.events["parallel: premature end"] != 1 {
+		t.Fatal("expected a premature end event")
+	}
+	if l.events["parallel: task failed, failing fast"] != 1 {
+		t.Fatal("expected a fail fast event")
+	}
+	opts := Options{Workers: 2, Timeout: 5 * time.Millisecond, Logger: l}
+	if err := RunWith([]Tasker{&sleeper{d: 50 * time.Millisecond}}, opts); err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	if l.events["parallel: timeout reached"] != 1 {
+		t.Fatal("expected a timeout event")
+	}
+}
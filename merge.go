@@ -0,0 +1,119 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrKeyCollision says that more Taskers produced the same key.
+var ErrKeyCollision = errors.New("key produced by more tasks")
+
+// MergeSorted merges already sorted slices into a new sorted slice.
+// Slices are merged pairwise as a tree: every level of the tree is
+// executed in parallel, halving the number of slices left.
+// less reports whether a must sort before b, merge is stable.
+// A panic of less is propagated.
+func MergeSorted[T any](slices [][]T, less func(a, b T) bool) []T {
+	if len(slices) == 0 {
+		return nil
+	}
+	for len(slices) > 1 {
+		merged := make([][]T, (len(slices)+1)/2)
+		tasks := make([]Tasker, 0, len(slices)/2)
+		for i := 0; i+1 < len(slices); i += 2 {
+			tasks = append(tasks, &mergeTask[T]{
+				a:    slices[i],
+				b:    slices[i+1],
+				less: less,
+				out:  &merged[i/2],
+			})
+		}
+		if len(slices)%2 == 1 {
+			merged[len(merged)-1] = slices[len(slices)-1]
+		}
+		runHelper(tasks, 0)
+		slices = merged
+	}
+	// Never return a caller's slice.
+	return append([]T(nil), slices[0]...)
+}
+
+// mergeTask merges two sorted slices into out.
+type mergeTask[T any] struct {
+	a, b []T
+	less func(a, b T) bool
+	out  *[]T
+}
+
+func (m *mergeTask[T]) Execute() {
+	r := make([]T, 0, len(m.a)+len(m.b))
+	i, j := 0, 0
+	for i < len(m.a) && j < len(m.b) {
+		if m.less(m.b[j], m.a[i]) {
+			r = append(r, m.b[j])
+			j++
+		} else {
+			r = append(r, m.a[i])
+			i++
+		}
+	}
+	r = append(r, m.a[i:]...)
+	r = append(r, m.b[j:]...)
+	*m.out = r
+}
+
+// MergeMaps combines the maps that extract returns for every Tasker
+// into one, once the run of tasks is done. When a key is produced
+// by more Taskers the value of the first one in tasks order is kept
+// and a *KeyCollisionError is returned along with the merged map.
+func MergeMaps[K comparable, V any](tasks []Tasker, extract func(Tasker) map[K]V) (map[K]V, error) {
+	merged := make(map[K]V)
+	// owner is the index of the Tasker whose value is kept.
+	owner := make(map[K]int)
+	collisions := make(map[K][]int)
+	for i, t := range tasks {
+		for k, v := range extract(t) {
+			if o, ok := owner[k]; ok {
+				if collisions[k] == nil {
+					collisions[k] = []int{o}
+				}
+				collisions[k] = append(collisions[k], i)
+				continue
+			}
+			owner[k] = i
+			merged[k] = v
+		}
+	}
+	if len(collisions) > 0 {
+		return merged, &KeyCollisionError[K]{Keys: collisions}
+	}
+	return merged, nil
+}
+
+// KeyCollisionError is returned by MergeMaps when more
+// Taskers produce the same key, it wraps ErrKeyCollision.
+type KeyCollisionError[K comparable] struct {
+	// Keys maps every colliding key to the indices,
+	// in ascending order, of Taskers producing it.
+	Keys map[K][]int
+}
+
+// Error lists colliding keys sorted by their string form.
+func (e *KeyCollisionError[K]) Error() string {
+	msgs := make([]string, 0, len(e.Keys))
+	for k, tasks := range e.Keys {
+		msgs = append(msgs, fmt.Sprintf("%v by tasks %v", k, tasks))
+	}
+	sort.Strings(msgs)
+	return fmt.Sprintf("%v: %s", ErrKeyCollision, strings.Join(msgs, ", "))
+}
+
+func (e *KeyCollisionError[K]) Unwrap() error {
+	return ErrKeyCollision
+}
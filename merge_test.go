@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// serialMerge is a naive k-way merge used as reference.
+func serialMerge(slices [][]int) []int {
+	var r []int
+	idx := make([]int, len(slices))
+	for {
+		min := -1
+		for i, s := range slices {
+			if idx[i] < len(s) && (min == -1 || s[idx[i]] < slices[min][idx[min]]) {
+				min = i
+			}
+		}
+		if min == -1 {
+			return r
+		}
+		r = append(r, slices[min][idx[min]])
+		idx[min]++
+	}
+}
+
+func TestMergeSorted(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	less := func(a, b int) bool { return a < b }
+	for _, k := range []int{1, 2, 3, 7, 16} {
+		slices := make([][]int, k)
+		for i := range slices {
+			s := make([]int, rng.Intn(100))
+			for j := range s {
+				s[j] = rng.Intn(1000)
+			}
+			sort.Ints(s)
+			slices[i] = s
+		}
+		got := MergeSorted(slices, less)
+		expected := serialMerge(slices)
+		if !reflect.DeepEqual(got, expected) && !(len(got) == 0 && len(expected) == 0) {
+			t.Fatalf("k=%d: merge differs from serial k-way merge", k)
+		}
+	}
+	if MergeSorted(nil, less) != nil {
+		t.Fatal("expected nil merging no slices")
+	}
+}
+
+func TestMergeMaps(t *testing.T) {
+	results := func(t Tasker) map[int]bool { return t.(*job).results }
+	tasks := []Tasker{&job{start: 1, stop: 10}, &job{start: 11, stop: 20}}
+	Run(tasks)
+	merged, err := MergeMaps(tasks, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 20 || !merged[17] || merged[18] {
+		t.Fatalf("unexpected merged map: %v", merged)
+	}
+	tasks = append(tasks, &job{start: 20, stop: 21})
+	Run(tasks)
+	merged, err = MergeMaps(tasks, results)
+	if !errors.Is(err, ErrKeyCollision) {
+		t.Fatal("expected ErrKeyCollision, got", err)
+	}
+	if len(merged) != 21 {
+		t.Fatalf("expected 21 keys, got %d", len(merged))
+	}
+	tasks = append(tasks, &job{start: 20, stop: 21}, &job{start: 1, stop: 1})
+	Run(tasks)
+	_, err = MergeMaps(tasks, results)
+	var ce *KeyCollisionError[int]
+	if !errors.As(err, &ce) {
+		t.Fatal("expected a *KeyCollisionError, got", err)
+	}
+	expected := map[int][]int{1: {0, 4}, 20: {1, 2, 3}, 21: {2, 3}}
+	if !reflect.DeepEqual(ce.Keys, expected) {
+		t.Fatalf("expected collisions %v, got %v", expected, ce.Keys)
+	}
+	msg := "key produced by more tasks: 1 by tasks [0 4], 20 by tasks [1 2 3], 21 by tasks [2 3]"
+	if err.Error() != msg {
+		t.Fatalf("unexpected message %q", err)
+	}
+}
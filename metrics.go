@@ -0,0 +1,77 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"expvar"
+	"sync"
+)
+
+// Metrics receives counts of Taskers of runs and Pools, e.g. to
+// export them to expvar or to a Prometheus collector. Methods are
+// called from several goroutines.
+type Metrics interface {
+	// Submitted is called when a Tasker is queued for workers.
+	Submitted()
+	// Started is called when a worker starts a Tasker, that is
+	// in-flight until Done is called, waiting retries included.
+	Started()
+	// Done is called when a Tasker completes,
+	// failed tells whether it returned an error.
+	Done(failed bool)
+	// Retried is called when a failed ErrTasker is retried.
+	Retried()
+}
+
+// ExpvarMetrics is a Metrics publishing counters with expvar, as
+// a map with keys submitted, completed, failed, retried and in_flight.
+type ExpvarMetrics struct {
+	submitted, completed, failed, retried, inFlight *expvar.Int
+}
+
+// expvarMu serializes lookups and creations of expvar maps.
+var expvarMu sync.Mutex
+
+// NewExpvarMetrics returns an ExpvarMetrics publishing counters
+// under namespace, e.g. /debug/vars shows them as a map named
+// namespace. Counters already published under namespace are reused,
+// so that Metrics of the same namespace share them.
+func NewExpvarMetrics(namespace string) *ExpvarMetrics {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	m, ok := expvar.Get(namespace).(*expvar.Map)
+	if !ok {
+		m = expvar.NewMap(namespace)
+	}
+	counter := func(name string) *expvar.Int {
+		if c, ok := m.Get(name).(*expvar.Int); ok {
+			return c
+		}
+		c := new(expvar.Int)
+		m.Set(name, c)
+		return c
+	}
+	return &ExpvarMetrics{
+		submitted: counter("submitted"),
+		completed: counter("completed"),
+		failed:    counter("failed"),
+		retried:   counter("retried"),
+		inFlight:  counter("in_flight"),
+	}
+}
+
+func (m *ExpvarMetrics) Submitted() { m.submitted.Add(1) }
+
+func (m *ExpvarMetrics) Started() { m.inFlight.Add(1) }
+
+func (m *ExpvarMetrics) Done(failed bool) {
+	m.inFlight.Add(-1)
+	m.completed.Add(1)
+	if failed {
+		m.failed.Add(1)
+	}
+}
+
+func (m *ExpvarMetrics) Retried() { m.retried.Add(1) }
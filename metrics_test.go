@@ -0,0 +1,110 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"expvar"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingMetrics is a Metrics counting calls.
+type countingMetrics struct {
+	submitted, started, completed, failed, retried int32
+}
+
+func (m *countingMetrics) Submitted() { atomic.AddInt32(&m.submitted, 1) }
+
+func (m *countingMetrics) Started() { atomic.AddInt32(&m.started, 1) }
+
+func (m *countingMetrics) Done(failed bool) {
+	atomic.AddInt32(&m.completed, 1)
+	if failed {
+		atomic.AddInt32(&m.failed, 1)
+	}
+}
+
+func (m *countingMetrics) Retried() { atomic.AddInt32(&m.retried, 1) }
+
+func (m *countingMetrics) check(t *testing.T, submitted, completed, failed, retried int32) {
+	t.Helper()
+	if s := atomic.LoadInt32(&m.submitted); s != submitted {
+		t.Errorf("expected %d submitted, got %d", submitted, s)
+	}
+	if s := atomic.LoadInt32(&m.started); s != completed {
+		t.Errorf("expected %d started, got %d", completed, s)
+	}
+	if c := atomic.LoadInt32(&m.completed); c != completed {
+		t.Errorf("expected %d completed, got %d", completed, c)
+	}
+	if f := atomic.LoadInt32(&m.failed); f != failed {
+		t.Errorf("expected %d failed, got %d", failed, f)
+	}
+	if r := atomic.LoadInt32(&m.retried); r != retried {
+		t.Errorf("expected %d retried, got %d", retried, r)
+	}
+}
+
+func TestRunWith_metrics(t *testing.T) {
+	for _, n := range testWorkers {
+		m := &countingMetrics{}
+		jobs := []Tasker{&dummy{}, panicker{}, &dummy{}}
+		RunWith(jobs, Options{Workers: n, Metrics: m})
+		m.check(t, 3, 3, 1, 0)
+	}
+}
+
+func TestRunErrWith_metrics(t *testing.T) {
+	for _, n := range testWorkers {
+		m := &countingMetrics{}
+		tasks := []ErrTasker{&flaky{failures: 2}, &flaky{failures: 5}, &flaky{}}
+		RunErrWith(tasks, Options{
+			Workers:      n,
+			MaxRetries:   3,
+			RetryBackoff: time.Millisecond,
+			Metrics:      m,
+		})
+		m.check(t, 3, 3, 1, 5)
+	}
+}
+
+func TestPool_metrics(t *testing.T) {
+	m := &countingMetrics{}
+	p := NewPool(2)
+	defer p.Close()
+	p.SetMetrics(m)
+	p.Submit([]Tasker{&dummy{}, panicker{}, &dummy{}})
+	m.check(t, 3, 3, 1, 0)
+	p.SetMetrics(nil)
+	p.Submit([]Tasker{&dummy{}})
+	m.check(t, 3, 3, 1, 0)
+}
+
+func TestNewExpvarMetrics(t *testing.T) {
+	m := NewExpvarMetrics("parallel_test")
+	vars := expvar.Get("parallel_test").(*expvar.Map)
+	value := func(k string) int64 { return vars.Get(k).(*expvar.Int).Value() }
+	// Counters survive across tests run more than once.
+	before := map[string]int64{}
+	for _, k := range []string{"submitted", "completed", "failed", "retried", "in_flight"} {
+		before[k] = value(k)
+	}
+	RunWith([]Tasker{&dummy{}, panicker{}}, Options{Metrics: m})
+	// Counters of the same namespace are shared.
+	RunWith([]Tasker{&dummy{}}, Options{Metrics: NewExpvarMetrics("parallel_test")})
+	want := map[string]int64{
+		"submitted": 3,
+		"completed": 3,
+		"failed":    1,
+		"retried":   0,
+		"in_flight": 0,
+	}
+	for k, v := range want {
+		if got := value(k) - before[k]; got != v {
+			t.Errorf("expected %s to grow by %d, got %d", k, v, got)
+		}
+	}
+}
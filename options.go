@@ -0,0 +1,388 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Strategy selects how Taskers are run.
+type Strategy int
+
+const (
+	// StrategyChannels feeds Taskers to a fixed number of
+	// workers through a channel, see Run.
+	StrategyChannels Strategy = iota
+	// StrategyWaitGroup starts a goroutine for every
+	// Tasker, see RunSync.
+	StrategyWaitGroup
+)
+
+// ErrorMode selects how errors of a run are combined
+// into the returned error.
+type ErrorMode int
+
+const (
+	// ErrJoin joins all errors with errors.Join.
+	ErrJoin ErrorMode = iota
+	// ErrFirst returns only the error that aborted the run
+	// or, if none, the error of the first failed Tasker
+	// in jobs order.
+	ErrFirst
+	// ErrCollectAll returns an *Errors holding
+	// the error of every Tasker.
+	ErrCollectAll
+)
+
+// OverflowPolicy selects what streaming runs do with results
+// when the buffer for the consumer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes workers wait for the consumer,
+	// so that a slow consumer slows down the run.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered result
+	// to make room for the new one.
+	OverflowDropOldest
+	// OverflowDropNewest drops the new result.
+	OverflowDropNewest
+)
+
+// Options configures a single run. The zero value is
+// the configuration used by Run.
+type Options struct {
+	// Workers is the number of workers executing Taskers, no more
+	// than Taskers are started. Zero means the package default, see
+	// SetWorkers.
+	// With a single worker, unless StallTimeout or ShutdownGrace
+	// are set, Taskers are executed in the calling goroutine.
+	Workers int
+	// QueueDepth is the number of Taskers buffered for workers,
+	// zero means the number of workers. A deeper queue smooths out
+	// differences in Taskers duration, a depth of 1 gives tight
+	// backpressure. When a signal aborts the run Taskers already
+	// buffered are still executed, so up to QueueDepth of them
+	// can start after the signal.
+	QueueDepth int
+	// Timeout, if not zero, is the maximum duration of the run.
+	// When it expires no new Taskers are started and
+	// ErrTimeout is returned.
+	Timeout time.Duration
+	// TaskTimeout, if not zero, is the maximum duration of a single
+	// Tasker. A worker stops waiting for a Tasker that exceeds it,
+	// recording an error wrapping ErrTaskTimeout, and moves to the
+	// next one. The Execute call itself can not be stopped by Go and
+	// keeps running in background, but the run does not wait for it.
+	TaskTimeout time.Duration
+	// StallTimeout, if not zero, is the maximum time the run waits
+	// for the next Tasker to complete. When it expires the run
+	// returns an error wrapping ErrStalled without waiting for
+	// running Taskers, which are abandoned. It's a safety net to
+	// detect deadlocked Taskers during development.
+	StallTimeout time.Duration
+	// WarnAfter, if not zero, makes the run log a "task running long"
+	// event to Logger, with its index, worker and running time, for
+	// every Tasker still running after it. Taskers are not aborted.
+	// It helps finding the input that drags out a run.
+	WarnAfter time.Duration
+	// ShutdownGrace, if not zero, is how long running Taskers are
+	// waited for once a signal or ctx aborted the run. When it expires
+	// the run returns without waiting further, running Taskers are
+	// abandoned: their workers exit as soon as they return, Taskers
+	// still buffered are not started. Zero means waiting for them
+	// indefinitely.
+	ShutdownGrace time.Duration
+	// OnProgress, if not nil, is called every time a Tasker completes
+	// with the number of completed Taskers, the total and an estimate
+	// of the remaining time based on the average duration of completed
+	// Taskers. The estimate is rough when Taskers have uneven costs.
+	// It's called serially from the goroutine that started the run.
+	OnProgress func(done, total int, eta time.Duration)
+	// OnComplete, if not nil, is called every time a Tasker completes
+	// without errors, with the Tasker in jobs, e.g. to validate or
+	// persist its result. Its error, or panic, is the error of the
+	// Tasker, so FailFast and MaxErrors account for it. It's called
+	// serially from the goroutine that started the run, before
+	// OnProgress.
+	OnComplete func(t Tasker) error
+	// Signals that abort the run making it return
+	// ErrTasksNotCompleted. If nil, os.Interrupt and SIGTERM
+	// are trapped. An empty non-nil slice disables signal handling.
+	Signals []os.Signal
+	// MaxRetries is how many times a failing ErrTasker is executed
+	// again before its error is reported, the last error is the one
+	// reported. Panics are failures too. No retries start once
+	// the run has been aborted, e.g. by FailFast or a signal.
+	MaxRetries int
+	// RetryBackoff is the pause before retrying a failed ErrTasker.
+	RetryBackoff time.Duration
+	// RetryJitter, if not zero, randomizes every RetryBackoff pause
+	// within ±RetryJitter times it, e.g. 0.1 for ±10%, so that
+	// Taskers failing together do not retry together. It must be
+	// in [0, 1].
+	RetryJitter float64
+	// CircuitBreaker, if not nil, fails Taskers with ErrCircuitOpen
+	// without executing them while it's open, that is after a number
+	// of consecutive failures and for a cooldown, so that a dead
+	// dependency is not hammered for the rest of the run, see
+	// NewCircuitBreaker. With RetryQueue every attempt of an ErrTasker
+	// counts, otherwise an ErrTasker counts once however many attempts
+	// it made. Taskers failed by it are not retried and count for
+	// FailFast and MaxErrors.
+	CircuitBreaker *CircuitBreaker
+	// RetryQueue, if not zero, is the capacity of a queue of failed
+	// ErrTaskers waiting to be retried. Rather than being retried in
+	// place, blocking their worker, they are taken from it only when
+	// no fresh Tasker is waiting, so that retries of flaky Taskers do
+	// not delay new work. When it's full retries happen in place.
+	RetryQueue int
+	// RatePerSecond, if not zero, is the maximum number of Taskers
+	// started per second by all workers combined. Starts are evenly
+	// spaced, bursts are not allowed.
+	RatePerSecond float64
+	// LoadGate, if not nil, is called by workers before starting every
+	// Tasker: while it returns false no Tasker is started, e.g. to back
+	// off while the system is under CPU or memory pressure. It's called
+	// from several goroutines and must be cheap.
+	LoadGate func() bool
+	// LoadGateInterval is how often a LoadGate that returned false
+	// is called again, 100ms if zero.
+	LoadGateInterval time.Duration
+	// FailFast stops the run when a Tasker fails: no new Taskers are
+	// started, running ones are left to finish and the first error
+	// is returned.
+	FailFast bool
+	// MaxErrors, if not zero, stops the run once that many Taskers
+	// failed, as FailFast does with one. ErrTooManyErrors is returned
+	// along with errors of Taskers.
+	MaxErrors int
+	// MaxConcurrentResource, if not zero, is the maximum number of
+	// ResourceTaskers reporting themselves as resource bound that
+	// are executed at the same time, e.g. the size of a connection
+	// pool shared by them. Other Taskers use all workers. A Tasker
+	// abandoned due to TaskTimeout releases its slot.
+	MaxConcurrentResource int
+	// GoroutineLimit, if not zero, is the maximum number of goroutines
+	// running Taskers at the same time, workers included: goroutines
+	// that GoTaskers start through spawn take the slots left by workers.
+	// When none is free spawn runs the function in the calling
+	// goroutine, so that fan-out Taskers keep memory bounded.
+	// Workers are at most GoroutineLimit.
+	GoroutineLimit int
+	// Weighted dispatches WeightedTaskers in order of decreasing
+	// weight, Taskers without a weight are dispatched last.
+	// It reduces the time cores remain idle at the end of a run of
+	// Taskers with uneven costs.
+	Weighted bool
+	// Prioritized dispatches PriorityTaskers in order of decreasing
+	// priority, Taskers without a priority have priority zero.
+	// Taskers of equal priority keep their order, or are sorted by
+	// weight if Weighted is set too.
+	Prioritized bool
+	// Affinity executes AffinityTaskers with the same key on the same
+	// worker, its key modulo the number of workers, through a queue of
+	// QueueDepth Taskers for each worker. Other Taskers go to the
+	// shared queue, taken by any worker. Once the queue of a busy
+	// worker is full Taskers after it wait, unless WorkStealing is set.
+	// It applies to runs of a slice of jobs with more than one worker.
+	Affinity bool
+	// WorkStealing, along with Affinity, trades locality for balance:
+	// AffinityTaskers for a worker whose queue is full go to the shared
+	// one, and workers with nothing left to do execute AffinityTaskers
+	// queued for others.
+	WorkStealing bool
+	// LockWorkerThreads locks every worker to its own OS thread with
+	// runtime.LockOSThread while the run lasts, reducing migrations of
+	// tight CPU bound loops. A locked thread executes only its worker,
+	// so that Workers should stay below GOMAXPROCS, and thread state
+	// changed by a Tasker, e.g. through cgo, is seen by later Taskers of
+	// the same worker. With a single worker the goroutine starting the
+	// run is locked.
+	LockWorkerThreads bool
+	// SingleFlight executes at most one KeyedTasker for every key at
+	// a time: a KeyedTasker whose key is being executed waits for it
+	// and shares its error instead of being executed, RunCollectWith
+	// returns the Result of the executed one for it. Once done a key
+	// can be executed again by later Taskers. It applies to runs with
+	// more than one worker.
+	SingleFlight bool
+	// Shuffle dispatches Taskers in a random order, reproducible with
+	// the same ShuffleSeed, e.g. to surface Taskers depending on the
+	// order of jobs or to spread expensive Taskers clustered in it.
+	// Taskers of equal weight or priority are shuffled with Weighted
+	// and Prioritized. Indices in errors and stats still refer to jobs.
+	Shuffle bool
+	// ShuffleSeed is the seed of Shuffle.
+	ShuffleSeed int64
+	// GOMAXPROCS, if not zero, is set with runtime.GOMAXPROCS for
+	// the duration of the run, the previous value is restored on
+	// return. It helps IO bound Taskers needing more threads than
+	// cores. As GOMAXPROCS is global it affects runs in progress too.
+	GOMAXPROCS int
+	// SerialOnSingleProc runs with a single worker once GOMAXPROCS,
+	// after applying the field above, is 1: workers could not execute
+	// Taskers in parallel anyway, e.g. when a container runtime sets
+	// it. Logger is warned the first time it happens in the process.
+	SerialOnSingleProc bool
+	// Logger, if not nil, receives events of the run: its start
+	// with the settings in use, e.g. the number of workers, workers
+	// starting and finishing, the queue closing, the cause of an
+	// abort (signal, timeout, FailFast...) and the error that
+	// ended the run. It's called from several goroutines.
+	Logger Logger
+	// Metrics, if not nil, receives counts of submitted, started,
+	// completed, failed and retried Taskers. Without it no counting
+	// happens.
+	Metrics Metrics
+	// Checkpoint, if not nil, makes the run resumable: Taskers that
+	// Load reports as completed are not executed, and Save is called
+	// with the ones completed so far, e.g. to persist them to a file.
+	// Failed Taskers are not completed, a resumed run executes them
+	// again. Save is called serially from the goroutine that started
+	// the run, at most once every CheckpointInterval and when the run
+	// returns. Indices refer to jobs, that must be the same for all
+	// runs sharing the Checkpoint.
+	Checkpoint Checkpoint
+	// CheckpointInterval is the minimum time between calls to
+	// Checkpoint.Save, zero means after every completed Tasker.
+	CheckpointInterval time.Duration
+	// Scheduler, if not nil, decides the order Taskers are
+	// dispatched in place of Weighted and Prioritized, see Scheduler.
+	// The order is computed once, before the run starts. Taskers it
+	// does not return are not executed and the run returns an error
+	// wrapping ErrNotDispatched. By default Taskers are dispatched
+	// in jobs order.
+	Scheduler Scheduler
+	// Dedup executes only once a pointer Tasker that appears more
+	// than once in jobs, later occurrences are skipped. Taskers are
+	// compared by pointer identity, not by value, other Taskers are
+	// always executed.
+	Dedup bool
+	// Deterministic executes Taskers one at a time in jobs order,
+	// overriding Workers, Weighted, Prioritized, Shuffle and Scheduler,
+	// so that runs are reproducible e.g. in golden file tests.
+	Deterministic bool
+	// DryRun dispatches Taskers without executing them, simulating
+	// workers that take the next Tasker as soon as they are free. With
+	// RunWithStats the returned stats are the plan: the worker of every
+	// Tasker and, if Cost is set, estimated durations and Elapsed.
+	// Signals, timeouts and options limiting concurrency do not apply.
+	DryRun bool
+	// Cost, if not nil, estimates how long a Tasker takes to execute
+	// in a DryRun. By default all Taskers are assumed to take the
+	// same time and no duration is estimated.
+	Cost func(Tasker) time.Duration
+	// ResultBufferSize is the number of results of streaming runs,
+	// e.g. RunNonBlockingWith and RunStreamWith, buffered for the
+	// consumer. Zero or less means the number of workers.
+	ResultBufferSize int
+	// Overflow is what streaming runs do with a result when the
+	// buffer is full, OverflowBlock by default. With drop policies
+	// results are lost by design whenever the consumer falls behind,
+	// use them only when that is acceptable, e.g. for live metrics.
+	// Unknown policies block.
+	Overflow OverflowPolicy
+	// ErrorMode shapes the returned error, ErrJoin by default.
+	ErrorMode ErrorMode
+	// Strategy to use, StrategyChannels by default.
+	// StrategyWaitGroup ignores all other options
+	// but ErrorMode and GOMAXPROCS.
+	Strategy Strategy
+}
+
+// defaultSignals are trapped when Options.Signals is nil.
+var defaultSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// Normalize returns o with defaults resolved as a run would
+// at this time: the number of Workers (see SetWorkers), QueueDepth
+// and trapped Signals. Running with the returned Options behaves
+// the same, whatever later calls to SetWorkers.
+func (o Options) Normalize() Options {
+	o.Workers = o.workers()
+	o.QueueDepth = o.queueDepth(o.Workers)
+	o.Signals = append([]os.Signal{}, o.signals()...)
+	return o
+}
+
+// workers returns the number of workers to use.
+func (o Options) workers() int {
+	if o.Deterministic {
+		return 1
+	}
+	n := o.Workers
+	if n == 0 {
+		n = workers()
+	}
+	if o.GoroutineLimit > 0 {
+		n = min(n, o.GoroutineLimit)
+	}
+	return n
+}
+
+// queueDepth returns the size of the queue for n workers.
+func (o Options) queueDepth(n int) int {
+	if o.QueueDepth == 0 {
+		return n
+	}
+	return o.QueueDepth
+}
+
+// resultBufferSize returns the size of the buffer
+// of results of streaming runs with n workers.
+func (o Options) resultBufferSize(n int) int {
+	if o.ResultBufferSize <= 0 {
+		return n
+	}
+	return o.ResultBufferSize
+}
+
+// signals returns signals to trap.
+func (o Options) signals() []os.Signal {
+	if o.Signals == nil {
+		return defaultSignals
+	}
+	return o.Signals
+}
+
+// validate returns an error if o can not be used for a run.
+func (o Options) validate() error {
+	if o.Workers < 0 {
+		return ErrInvalidWorkers
+	}
+	if o.GOMAXPROCS < 0 {
+		return ErrInvalidProcs
+	}
+	if o.QueueDepth < 0 || o.RetryQueue < 0 {
+		return ErrInvalidQueueDepth
+	}
+	if o.MaxErrors < 0 {
+		return ErrInvalidMaxErrors
+	}
+	if o.MaxConcurrentResource < 0 || o.GoroutineLimit < 0 {
+		return ErrInvalidResourceLimit
+	}
+	if o.RatePerSecond < 0 {
+		return ErrInvalidRate
+	}
+	if o.MaxRetries < 0 {
+		return ErrInvalidRetries
+	}
+	if o.RetryJitter < 0 || o.RetryJitter > 1 {
+		return ErrInvalidJitter
+	}
+	if o.Timeout < 0 || o.TaskTimeout < 0 || o.StallTimeout < 0 || o.ShutdownGrace < 0 || o.RetryBackoff < 0 || o.LoadGateInterval < 0 || o.CheckpointInterval < 0 || o.WarnAfter < 0 {
+		return ErrInvalidTimeout
+	}
+	if o.Strategy != StrategyChannels && o.Strategy != StrategyWaitGroup {
+		return ErrInvalidStrategy
+	}
+	if o.ErrorMode < ErrJoin || o.ErrorMode > ErrCollectAll {
+		return ErrInvalidErrorMode
+	}
+	return nil
+}
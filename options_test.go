@@ -0,0 +1,54 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOptions_normalize(t *testing.T) {
+	withWorkers(t, 3)
+	o := Options{}.Normalize()
+	if o.Workers != 3 || o.QueueDepth != 3 {
+		t.Fatalf("expected 3 workers and queue depth 3, got %d and %d", o.Workers, o.QueueDepth)
+	}
+	if !reflect.DeepEqual(o.Signals, defaultSignals) {
+		t.Fatal("unexpected signals", o.Signals)
+	}
+	// Later changes of defaults do not affect normalized Options.
+	withWorkers(t, 5)
+	if n := o.Normalize(); !reflect.DeepEqual(n, o) {
+		t.Fatalf("normalizing twice changed options: %+v", n)
+	}
+	o = Options{Workers: 7, QueueDepth: 1, Signals: []os.Signal{}, Deterministic: true}.Normalize()
+	if o.Workers != 1 || o.QueueDepth != 1 || o.Signals == nil || len(o.Signals) != 0 {
+		t.Fatalf("unexpected normalized options: %+v", o)
+	}
+}
+
+func TestRunWith_normalizedLogged(t *testing.T) {
+	l := &argsLogger{}
+	initTests()
+	if err := RunWith(testCases, Options{Workers: 2, Logger: l}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []any{"tasks", len(testCases), "workers", 2, "queue", 2}
+	if !reflect.DeepEqual(l.started, expected) {
+		t.Fatalf("expected %v, got %v", expected, l.started)
+	}
+}
+
+// argsLogger keeps the arguments of the run started event.
+type argsLogger struct {
+	started []any
+}
+
+func (l *argsLogger) Info(msg string, args ...any) {
+	if msg == "parallel: run started" {
+		l.started = args
+	}
+}
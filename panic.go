@@ -0,0 +1,76 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// PanicError is returned when a Tasker panics, the panic is
+// recovered so that remaining Taskers are still executed.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("task panicked: %v", e.Value)
+}
+
+// recoverPanic must be deferred, it converts
+// a panic into a *PanicError stored in err.
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = &PanicError{Value: r, Stack: debug.Stack()}
+	}
+}
+
+// errExecutor is implemented by Taskers that can fail.
+type errExecutor interface {
+	executeErr() error
+}
+
+// execute calls t.Execute() returning a *PanicError if it panics.
+// If t is an errExecutor its error is returned.
+// A nil t is not executed, ErrNilTask is returned.
+func execute(t Tasker) (err error) {
+	if t == nil {
+		return ErrNilTask
+	}
+	defer recoverPanic(&err)
+	if e, ok := t.(errExecutor); ok {
+		return e.executeErr()
+	}
+	t.Execute()
+	return nil
+}
+
+// errCollector collects errors from concurrent workers.
+type errCollector struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (c *errCollector) add(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// join returns err joined with collected errors.
+// If no errors have been collected err is returned as is.
+func (c *errCollector) join(err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.errs) == 0 {
+		return err
+	}
+	return errors.Join(append([]error{err}, c.errs...)...)
+}
@@ -12,13 +12,15 @@ package parallel
 // NOTE Useful for debugging on Linux: pidstat -tu  -C '<pid-name>'  1
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
 	"os/signal"
 	"runtime"
 	"sync"
-
-	"github.com/eraclitux/trace"
+	"sync/atomic"
+	"time"
 )
 
 // Tasker interface models an heavy task that have to be
@@ -27,100 +29,727 @@ type Tasker interface {
 	Execute()
 }
 
+// TaskFunc is an adapter to allow the use of ordinary
+// functions as Taskers.
+type TaskFunc func()
+
+// Execute calls f().
+func (f TaskFunc) Execute() {
+	f()
+}
+
+// Canceler is implemented by Taskers that need to release
+// resources when a run is aborted before they are started.
+type Canceler interface {
+	OnCancel()
+}
+
 // ErrTasksNotCompleted says that not all tasks where completed.
-var ErrTasksNotCompleted = errors.New("SIGINT received, not all tasks have been completed")
+var ErrTasksNotCompleted = errors.New("signal received, not all tasks have been completed")
+
+// ErrTimeout says that a run did not complete within its timeout.
+var ErrTimeout = errors.New("timeout reached, not all tasks have been completed")
+
+// ErrTooManyErrors says that Options.MaxErrors
+// Taskers failed and the run was stopped.
+var ErrTooManyErrors = errors.New("too many errors, not all tasks have been completed")
+
+// ErrStalled says that no Tasker completed
+// within Options.StallTimeout.
+var ErrStalled = errors.New("run stalled")
+
+// ErrTaskTimeout says that a Tasker did not complete within
+// Options.TaskTimeout.
+var ErrTaskTimeout = errors.New("task timeout reached")
+
+// ErrNilTask says that a nil Tasker has been skipped.
+var ErrNilTask = errors.New("nil task skipped")
+
+// ErrInvalidTimeout says that a timeout is negative.
+var ErrInvalidTimeout = errors.New("timeout must not be negative")
+
+// ErrInvalidStrategy says that a Strategy is unknown.
+var ErrInvalidStrategy = errors.New("unknown strategy")
+
+// ErrInvalidErrorMode says that an ErrorMode is unknown.
+var ErrInvalidErrorMode = errors.New("unknown error mode")
+
+// ErrInvalidRetries says that a number of retries is negative.
+var ErrInvalidRetries = errors.New("number of retries must not be negative")
+
+// ErrInvalidJitter says that RetryJitter is outside [0, 1].
+var ErrInvalidJitter = errors.New("retry jitter must be in [0, 1]")
+
+// ErrInvalidRate says that a rate is negative.
+var ErrInvalidRate = errors.New("rate must not be negative")
 
-var workersNumber int = runtime.NumCPU()
+// ErrInvalidQueueDepth says that a queue depth is negative.
+var ErrInvalidQueueDepth = errors.New("queue depth must not be negative")
+
+// ErrInvalidResourceLimit says that a limit
+// of resource bound Taskers is negative.
+var ErrInvalidResourceLimit = errors.New("resource limit must not be negative")
+
+// ErrInvalidMaxErrors says that a maximum number of errors is negative.
+var ErrInvalidMaxErrors = errors.New("maximum number of errors must not be negative")
+
+// ErrInvalidProcs says that GOMAXPROCS is negative.
+var ErrInvalidProcs = errors.New("GOMAXPROCS must not be negative")
+
+// ErrInvalidWorkers says that a number of workers is less than 1.
+var ErrInvalidWorkers = errors.New("number of workers must be at least 1")
+
+// workersNumber is accessed atomically as
+// it can be changed while runs are in progress.
+var workersNumber int32 = int32(defaultWorkers())
+
+// SetWorkers sets the number of workers used by runs started
+// afterwards, by default it's the number of available cores.
+// Runs already in progress are not affected. If n is less than 1
+// ErrInvalidWorkers is returned and the number is left untouched.
+func SetWorkers(n int) error {
+	if n < 1 {
+		return ErrInvalidWorkers
+	}
+	atomic.StoreInt32(&workersNumber, int32(n))
+	return nil
+}
+
+// workers returns the current number of workers.
+func workers() int {
+	return int(atomic.LoadInt32(&workersNumber))
+}
+
+// notifySignal is signal.Notify, replaced in tests.
+var notifySignal = signal.Notify
 
 // Run starts the goroutines that will execute Taskers.
 // It is intended to run blocking in the main goroutine.
+// A Tasker that panics does not stop the run, panics are recovered
+// and returned as *PanicError, joined with other errors.
+// Errors of Taskers are wrapped in *TaskError.
+// A nil or empty jobs returns nil immediately.
 func Run(jobs []Tasker) (err error) {
+	return RunContext(context.Background(), jobs)
+}
+
+// MustRun is like Run but panics if Run returns an error.
+// It's intended for scripts and tests, where a failure is fatal.
+func MustRun(jobs []Tasker) {
+	if err := Run(jobs); err != nil {
+		panic(err)
+	}
+}
+
+// RunContext is like Run but stops feeding Taskers to workers
+// as soon as ctx is done. Taskers already running are left to finish,
+// then an error wrapping ctx.Err() is returned.
+func RunContext(ctx context.Context, jobs []Tasker) (err error) {
+	return run(ctx, jobs, Options{})
+}
+
+// RunFuncs is like Run but executes plain functions.
+func RunFuncs(fns []func()) error {
+	tasks := make([]Tasker, len(fns))
+	for i, f := range fns {
+		tasks[i] = TaskFunc(f)
+	}
+	return Run(tasks)
+}
+
+// RunTimeout is like Run but returns ErrTimeout if jobs are not
+// completed within d. No new Taskers are started after d,
+// running ones are left to finish.
+func RunTimeout(jobs []Tasker, d time.Duration) error {
+	return RunWith(jobs, Options{Timeout: d})
+}
+
+// RunWith is like Run but uses opts for this run only,
+// it's safe to use concurrently with different Options.
+func RunWith(jobs []Tasker, opts Options) error {
+	return run(context.Background(), jobs, opts)
+}
+
+func run(ctx context.Context, jobs []Tasker, opts Options) error {
+	return runTasks(ctx, jobs, opts).error()
+}
+
+// report is the outcome of a run.
+type report struct {
+	// tasks are the jobs of the run.
+	tasks []Tasker
+	// errs are errors of single Taskers, in jobs order.
+	errs []error
+	// executed tells which Taskers have been executed.
+	executed []bool
+	// planned tells which Taskers a DryRun dispatched,
+	// they are not executed.
+	planned []bool
+	// err is the error that aborted the run, if any.
+	err error
+	// durations are execution times of single Taskers.
+	durations []time.Duration
+	// assigned are the workers that executed single Taskers.
+	assigned []int
+	// workers are statistics of every worker.
+	workers []WorkerStats
+	// elapsed is the wall-clock duration of the run.
+	elapsed time.Duration
+	// mode shapes the returned error.
+	mode ErrorMode
+	// finished and failed count executed Taskers.
+	finished, failed int
+	// busy is the time spent executing Taskers.
+	busy time.Duration
+	// abortedBy is the index of the Tasker whose failure
+	// aborted the run with FailFast, -1 if none.
+	abortedBy int
+	// checkpoint, if not nil, tracks completed Taskers.
+	checkpoint *checkpointer
+	// leaders maps Taskers that shared the outcome of another
+	// one, see Options.SingleFlight, to its index.
+	leaders map[int]int
+	// maxQueued is the high-water mark of queued Taskers,
+	// written by populateQueue while the run goes on.
+	maxQueued atomic.Int64
+}
+
+func newReport(jobs []Tasker) *report {
+	n := len(jobs)
+	return &report{
+		tasks:     jobs,
+		errs:      make([]error, n),
+		executed:  make([]bool, n),
+		durations: make([]time.Duration, n),
+		assigned:  make([]int, n),
+		abortedBy: -1,
+		leaders:   make(map[int]int),
+	}
+}
+
+// error returns the error that aborted the run
+// and errors of single Taskers, combined as r.mode says.
+// Errors of Taskers are wrapped in *TaskError.
+func (r *report) error() error {
+	abort := r.err
+	var errs []error
+	for i, e := range r.errs {
+		switch {
+		case e == nil:
+		case i == r.abortedBy:
+			// With FailFast r.err is also a Tasker error.
+			abort = r.taskError(i)
+		default:
+			errs = append(errs, r.taskError(i))
+		}
+	}
+	if len(errs) == 0 {
+		return abort
+	}
+	switch r.mode {
+	case ErrFirst:
+		if abort != nil {
+			return abort
+		}
+		return errs[0]
+	case ErrCollectAll:
+		return &Errors{Err: r.err, Tasks: r.errs, abortedBy: r.abortedBy + 1}
+	}
+	return errors.Join(append([]error{abort}, errs...)...)
+}
+
+// taskError returns the error of the i-th Tasker as a *TaskError.
+func (r *report) taskError(i int) error {
+	return &TaskError{Index: i, Task: r.tasks[i], Err: r.errs[i]}
+}
+
+// record stores the outcome of a Tasker, setting r.err if
+// the run must stop as opts say. It returns true in that case.
+func (r *report) record(d taskDone, opts Options, total int) (stop bool) {
+	if d.err == errSkipped {
+		// The Tasker found the run stopped and was
+		// not executed, as buffered ones are not.
+		return false
+	}
+	if d.err == nil && opts.OnComplete != nil {
+		d.err = complete(opts.OnComplete, r.tasks[d.index])
+	}
+	r.finished++
+	r.executed[d.index] = true
+	r.errs[d.index] = d.err
+	r.durations[d.index] = d.duration
+	r.assigned[d.index] = d.worker
+	r.workers[d.worker].Tasks++
+	r.workers[d.worker].Busy += d.duration
+	r.busy += d.duration
+	if d.leader != d.index {
+		r.leaders[d.index] = d.leader
+	}
+	if d.err != nil {
+		r.failed++
+	} else {
+		r.checkpoint.completed(d.index)
+	}
+	if d.err != nil && opts.FailFast && r.err == nil {
+		logEvent(opts.Logger, "parallel: task failed, failing fast", "task", d.index, "err", d.err)
+		r.err = d.err
+		r.abortedBy = d.index
+		stop = true
+	}
+	if opts.MaxErrors > 0 && r.failed == opts.MaxErrors && r.err == nil {
+		logEvent(opts.Logger, "parallel: too many errors", "errors", r.failed)
+		r.err = ErrTooManyErrors
+		stop = true
+	}
+	if opts.OnProgress != nil {
+		opts.OnProgress(r.finished, total, r.eta(total))
+	}
+	return stop
+}
+
+// complete calls f on t returning a *PanicError if it panics.
+func complete(f func(Tasker) error, t Tasker) (err error) {
+	defer recoverPanic(&err)
+	return f(t)
+}
+
+// eta estimates the time needed to complete total Taskers
+// from the average duration of the ones already executed.
+func (r *report) eta(total int) time.Duration {
+	avg := r.busy / time.Duration(r.finished)
+	return avg * time.Duration(total-r.finished) / time.Duration(len(r.workers))
+}
+
+// runTasks is the implementation of all runs.
+func runTasks(ctx context.Context, jobs []Tasker, opts Options) *report {
+	start := time.Now()
+	r := newReport(jobs)
+	r.mode = opts.ErrorMode
+	defer func() {
+		// A dry run estimates its own elapsed time.
+		if !opts.DryRun {
+			r.elapsed = time.Since(start)
+		}
+	}()
+	if err := opts.validate(); err != nil {
+		r.err = err
+		return r
+	}
+	// Nothing to do, do not start any goroutine.
+	if len(jobs) == 0 {
+		return r
+	}
+	if opts.GOMAXPROCS > 0 {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(opts.GOMAXPROCS))
+	}
+	if opts.Strategy == StrategyWaitGroup {
+		r = runSync(jobs)
+		r.mode = opts.ErrorMode
+		return r
+	}
 	// []T does not convert to []Tasker implicitly even is T implements
 	// Tasker. We need to iterate on []Tasker making an explicit cast.
 	// http://golang.org/doc/faq#convert_slice_of_interface
-	prematureEnd := make(chan struct{})
-	jobsQueue := make(chan Tasker, workersNumber)
-	done := make(chan struct{}, workersNumber)
-	var totalDone int
-	go populateQueue(jobsQueue, jobs, prematureEnd)
-	go parallelizeWorkers(jobsQueue, done)
-	// TODO add a case timeout that returns error.
-	for {
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Workers beyond the number of Taskers would have nothing to do,
+	// the serial path is still taken only if a single one is asked.
+	asked := opts.workers()
+	if opts.SerialOnSingleProc && asked > 1 && singleProc(opts.Logger) {
+		asked = 1
+	}
+	n := min(asked, len(jobs))
+	r.workers = make([]WorkerStats, n)
+	logEvent(opts.Logger, "parallel: run started", "tasks", len(jobs), "workers", n, "queue", opts.queueDepth(n))
+	defer func() {
+		if r.err != nil {
+			logEvent(opts.Logger, "parallel: premature end", "err", r.err, "completed", r.finished)
+		}
+	}()
+	tasks, err := opts.schedule(jobs)
+	if err != nil {
+		// Dispatched Taskers are still executed.
+		defer func() {
+			if r.err == nil {
+				r.err = err
+			}
+		}()
+	}
+	r.checkpoint = newCheckpointer(opts, len(jobs))
+	defer r.checkpoint.save()
+	tasks = r.checkpoint.skip(tasks)
+	if opts.DryRun {
+		r.plan(tasks, opts.Cost)
+		return r
+	}
+	// Stalls and grace periods can not be
+	// detected while a Tasker blocks the run.
+	if asked == 1 && opts.StallTimeout == 0 && opts.ShutdownGrace == 0 {
+		runSerial(ctx, cancel, tasks, opts, r)
+		return r
+	}
+	var timeout <-chan time.Time
+	if opts.Timeout > 0 {
+		timer := time.NewTimer(opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var shutdown <-chan time.Time
+	var stall <-chan time.Time
+	var stallTimer *time.Timer
+	if opts.StallTimeout > 0 {
+		stallTimer = time.NewTimer(opts.StallTimeout)
+		defer stallTimer.Stop()
+		stall = stallTimer.C
+	}
+	// prematureEnd is buffered so that populateQueue
+	// never blocks when the run already returned.
+	prematureEnd := make(chan error, 1)
+	jobsQueue := make(chan indexedTask, opts.queueDepth(n))
+	aff := newAffinity(opts, n)
+	done := make(chan taskDone, n)
+	// stop tells workers that done is not read anymore.
+	stop := make(chan struct{})
+	defer close(stop)
+	go populateQueue(ctx, jobsQueue, aff, tasks, prematureEnd, opts.signals(), opts.Logger, opts.Metrics, &r.maxQueued)
+	go parallelizeWorkers(ctx, jobsQueue, aff, done, stop, n, opts)
+	for done != nil {
 		select {
-		case <-done:
-			totalDone++
-		case <-prematureEnd:
-			err = ErrTasksNotCompleted
+		case d, ok := <-done:
+			if !ok {
+				// We can assume that jobsQueue is closed and
+				// that no goroutine is operating on []Tasker.
+				done = nil
+				break
+			}
+			if stallTimer != nil {
+				if !stallTimer.Stop() {
+					<-stallTimer.C
+				}
+				stallTimer.Reset(opts.StallTimeout)
+			}
+			if r.record(d, opts, len(tasks)) {
+				cancel()
+			}
+		case e := <-prematureEnd:
+			if r.err == nil {
+				r.err = e
+			}
+			if opts.ShutdownGrace > 0 {
+				grace := time.NewTimer(opts.ShutdownGrace)
+				defer grace.Stop()
+				shutdown = grace.C
+			}
+		case <-shutdown:
+			logEvent(opts.Logger, "parallel: shutdown grace period expired", "grace", opts.ShutdownGrace)
+			// Running Taskers are abandoned.
+			return r
+		case <-timeout:
+			logEvent(opts.Logger, "parallel: timeout reached", "timeout", opts.Timeout)
+			r.err = ErrTimeout
+			// Stop populateQueue, its error is superseded.
+			cancel()
+		case <-stall:
+			logEvent(opts.Logger, "parallel: run stalled", "stall", opts.StallTimeout)
+			r.err = fmt.Errorf("%w: no task completed in %s, %d goroutines running",
+				ErrStalled, opts.StallTimeout, runtime.NumGoroutine())
+			cancel()
+			// Stuck workers are abandoned.
+			return r
 		}
-		if totalDone == workersNumber {
-			// We can assume that jobsQueue is closed and
-			// that no goroutine is operating on []Tasker.
-			break
+	}
+	// populateQueue sends its error before closing jobsQueue,
+	// done can be closed while it's still buffered.
+	select {
+	case e := <-prematureEnd:
+		if r.err == nil {
+			r.err = e
 		}
+	default:
 	}
-	return
+	// Workers skip buffered Taskers once ctx is done,
+	// e.g. while waiting for a LoadGate.
+	if r.err == nil && parent.Err() != nil && r.finished < len(tasks) {
+		r.err = fmt.Errorf("context done, not all tasks have been completed: %w", parent.Err())
+	}
+	return r
 }
 
-func populateQueue(jobsQueue chan<- Tasker, jobs []Tasker, prematureEnd chan<- struct{}) {
+// indexedTask is a Tasker along with its index in jobs.
+type indexedTask struct {
+	index int
+	Tasker
+}
+
+// populateQueue feeds jobsQueue with jobs, in order, sending
+// AffinityTaskers to the queues of their workers in aff, if not nil.
+// l, if not nil, receives an event when queues are closed,
+// m, if not nil, counts queued Taskers and maxQueued keeps
+// the largest number of them waiting in queues.
+func populateQueue(ctx context.Context, jobsQueue chan<- indexedTask, aff *affinity, jobs []indexedTask, prematureEnd chan<- error, signals []os.Signal, l Logger, m Metrics, maxQueued *atomic.Int64) {
+	defer func() {
+		logEvent(l, "parallel: queue closed")
+		close(jobsQueue)
+		aff.close()
+	}()
 	signalChan := make(chan os.Signal, 1)
-	signal.Notify(signalChan, os.Interrupt)
-	for _, t := range jobs {
+	// Notify with no signals would relay all of them.
+	if len(signals) > 0 {
+		notifySignal(signalChan, signals...)
+		// Do not accumulate registrations across runs.
+		defer signal.Stop(signalChan)
+	}
+	for i, t := range jobs {
+		q, spill := aff.queueFor(t.Tasker, jobsQueue)
 		select {
-		default:
-			jobsQueue <- t
-		case <-signalChan:
+		case q <- t:
+		case spill <- t:
+		case sig := <-signalChan:
 			// Abort jobs queue evaluation.
 			// Taskers already sended will be finished
 			// and an error will be returned.
-			trace.Println("parallel: received SIGINT")
-			prematureEnd <- struct{}{}
-			close(jobsQueue)
+			logEvent(l, "parallel: signal received", "signal", sig)
+			cancelRemaining(jobs[i:])
+			prematureEnd <- ErrTasksNotCompleted
 			return
+		case <-ctx.Done():
+			logEvent(l, "parallel: context done", "err", ctx.Err())
+			cancelRemaining(jobs[i:])
+			prematureEnd <- fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+			return
+		}
+		if m != nil {
+			m.Submitted()
+		}
+		if queued := int64(len(jobsQueue) + aff.queued()); queued > maxQueued.Load() {
+			maxQueued.Store(queued)
+		}
+	}
+}
+
+// cancelRemaining calls OnCancel on jobs that never
+// reached jobsQueue and implement Canceler, then
+// Cleanup on CleanupTaskers.
+func cancelRemaining(jobs []indexedTask) {
+	for _, t := range jobs {
+		if c, ok := t.Tasker.(Canceler); ok {
+			c.OnCancel()
 		}
+		cleanup(t.Tasker)
 	}
-	trace.Println("close jobsQueue")
-	close(jobsQueue)
 }
 
-// parallelizeWorkers creates a goroutine for every worker
-// which will call Execute() method.
-func parallelizeWorkers(jobsQueue <-chan Tasker, doneChan chan<- struct{}) {
-	for i := 0; i < workersNumber; i++ {
-		go evaluateQueue(jobsQueue, doneChan)
+// taskDone is sent by workers for every evaluated Tasker.
+type taskDone struct {
+	index int
+	// err is the error returned by an ErrTasker
+	// or a panic or timeout error.
+	err error
+	// task is the evaluated Tasker.
+	task Tasker
+	// worker is the worker that executed the Tasker.
+	worker int
+	// duration is how long the Tasker took.
+	duration time.Duration
+	// leader is the index of the Tasker whose outcome
+	// was shared, index itself if executed.
+	leader int
+}
+
+// parallelizeWorkers creates a goroutine for every one
+// of n workers which will call Execute() method.
+// Workers take Taskers from jobsQueue and from their
+// queue in aff, if not nil, see Options.Affinity.
+// doneChan is closed once all workers are done.
+// Workers return early once stop is closed.
+func parallelizeWorkers(ctx context.Context, jobsQueue <-chan indexedTask, aff *affinity, doneChan chan<- taskDone, stop <-chan struct{}, n int, opts Options) {
+	lim := newLimiter(opts.RatePerSecond)
+	sem := newSemaphore(opts.MaxConcurrentResource)
+	gl := newGoLimit(opts.GoroutineLimit, n)
+	wd := newWatchdog(opts, n)
+	defer wd.stop()
+	fl := newFlights(opts)
+	var retries retryQueue
+	if opts.RetryQueue > 0 {
+		retries = make(retryQueue, opts.RetryQueue)
+	}
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(id int) {
+			defer wg.Done()
+			q := newWorkerQueue(id, jobsQueue, aff)
+			evaluateQueue(ctx, id, q, doneChan, stop, opts, lim, sem, gl, wd, fl, retries)
+		}(i)
 	}
+	wg.Wait()
+	close(doneChan)
 }
 
 // evaluateQueue does jobs in sequence on its own goroutine
-// on a single core.
-func evaluateQueue(jobsQueue <-chan Tasker, doneChan chan<- struct{}) {
-	for j := range jobsQueue {
-		j.Execute()
+// on a single core, signaling every completed one on doneChan.
+// id identifies the worker.
+// lim, if not nil, throttles starts, as opts.LoadGate does, sem
+// bounds resource bound Taskers and gl goroutines of GoTaskers,
+// wd, if not nil, watches for Taskers running long and
+// fl shares outcomes of KeyedTaskers with the same key.
+// Once ctx is done remaining Taskers are not executed, as if they
+// never reached jobsQueue. Once
+// stop is closed the run has returned and nobody reads doneChan,
+// remaining Taskers are not executed either.
+// Failed ErrTaskers are put in retries, if not nil, and taken
+// back once jobsQueue is empty. YieldingTaskers that yielded are
+// resumed by the same worker once no Tasker waits in jobsQueue.
+func evaluateQueue(ctx context.Context, id int, jobsQueue *workerQueue, doneChan chan<- taskDone, stop <-chan struct{}, opts Options, lim *limiter, sem semaphore, gl *goLimit, wd *watchdog, fl *flights, retries retryQueue) {
+	if opts.LockWorkerThreads {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+	var executed int
+	logEvent(opts.Logger, "parallel: worker started", "worker", id)
+	defer func() {
+		logEvent(opts.Logger, "parallel: worker done", "worker", id, "tasks", executed)
+	}()
+	// yielded are YieldingTaskers to resume once no other waits.
+	var yielded []indexedTask
+	for {
+		var j indexedTask
+		var retried, ok bool
+		if len(yielded) == 0 || jobsQueue.waiting() {
+			j, retried, ok = retries.next(jobsQueue)
+		}
+		resumed := !ok && len(yielded) > 0
+		if resumed {
+			j, yielded = yielded[0], yielded[1:]
+		} else if !ok {
+			return
+		}
+		if ctx.Err() != nil || stopped(stop) || retried && !j.Tasker.(*errTask).wait(ctx) || !waitGate(ctx, opts) {
+			cancelRemaining([]indexedTask{j})
+			continue
+		}
+		lim.wait()
+		release := sem.acquire(j.Tasker)
+		if opts.Metrics != nil && !retried && !resumed {
+			opts.Metrics.Started()
+		}
+		pre := time.Now()
+		if y, ok := j.Tasker.(*yieldTask); ok {
+			y.waiting = jobsQueue.waiting
+			// Its duration adds up across executions.
+			pre = pre.Add(-y.ran)
+		}
+		wd.started(id, j.index)
+		leader, queued, err := fl.do(j, func() (bool, error) {
+			err := opts.CircuitBreaker.execute(ctx, gl.wrap(j), opts.TaskTimeout)
+			return retry(ctx, j, err, opts, retries.enqueue)
+		})
+		wd.done(id)
+		release()
+		if queued {
+			continue
+		}
+		if y, ok := resumable(ctx, j, err); ok {
+			y.ran = time.Since(pre)
+			yielded = append(yielded, j)
+			continue
+		}
+		if opts.Metrics != nil {
+			opts.Metrics.Done(err != nil)
+		}
+		executed++
+		if stopped(stop) {
+			// The run returned while j was running.
+			cleanup(j.Tasker)
+			continue
+		}
+		select {
+		case doneChan <- taskDone{
+			index:    j.index,
+			task:     j.Tasker,
+			err:      err,
+			worker:   id,
+			duration: time.Since(pre),
+			leader:   leader,
+		}:
+		case <-stop:
+			// Nobody reads doneChan anymore, j has been
+			// abandoned and buffered Taskers left are canceled.
+			cleanup(j.Tasker)
+		}
 	}
-	doneChan <- struct{}{}
 }
 
-// runSync is used to compare benchmark of parallelism
-// implemented with channels.
-func runSync(jobs []Tasker) (err error) {
+// stopped reports whether stop is closed, that is
+// the run returned and buffered Taskers are abandoned.
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// executeTimeout executes j returning an error wrapping ErrTaskTimeout
+// if it does not complete within d, zero meaning no timeout.
+// On timeout j is abandoned: its goroutine can not be stopped and
+// keeps running in background, a CleanupTasker is cleaned up once it
+// returns. A CtxTasker receives ctx, done on timeout too.
+// A DeadlineTasker is not executed once its deadline passed.
+func executeTimeout(ctx context.Context, j indexedTask, d time.Duration) error {
+	ctx, cancel, err := withDeadline(ctx, j.Tasker)
+	defer cancel()
+	if err != nil {
+		return err
+	}
+	if d == 0 {
+		return executeContext(ctx, j.Tasker)
+	}
+	ctx, cancelTimeout := context.WithTimeout(ctx, d)
+	defer cancelTimeout()
+	done := make(chan error, 1)
+	go func() { done <- executeContext(ctx, j.Tasker) }()
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		go func() {
+			<-done
+			cleanup(j.Tasker)
+		}()
+		return ErrTaskTimeout
+	}
+}
+
+// RunSync executes every Tasker on its own goroutine waiting
+// for all of them with a sync.WaitGroup. Unlike Run the number of
+// goroutines is not bounded to the number of workers: the Go scheduler
+// spreads them on available cores. Benchmarks show it's on par with Run
+// for few heavy Taskers while it consumes more memory for large batches.
+// Panics are recovered as in Run, signals are not trapped.
+func RunSync(jobs []Tasker) (err error) {
+	return runSync(jobs).error()
+}
+
+func runSync(jobs []Tasker) *report {
+	r := newReport(jobs)
 	var wg sync.WaitGroup
-	for _, j := range jobs {
-		j := j
+	for i, j := range jobs {
+		i, j := i, j
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			j.Execute()
+			// Every goroutine writes its own slots.
+			pre := time.Now()
+			r.errs[i] = execute(j)
+			r.durations[i] = time.Since(pre)
+			r.executed[i] = true
 		}()
 	}
 	wg.Wait()
-	return nil
+	return r
 }
-
-// TODO has a non blocking version a sense (API semplification, performance etc.)? Es:
-// When using Run one must wait that all tasks are done
-// and put separate results togherther in the end. RunNonBlocking avoids that.
-// func RunNonBlocking(jobs <-chan Tasker) (results chan<- Resulter) {
-//code
-//code
-// Comunicate to callers that we are done.
-// close(results)
-//}
@@ -5,8 +5,17 @@
 package parallel
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"os"
+	"os/signal"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -62,21 +71,62 @@ func initTests() {
 
 }
 
+// testWorkers are the numbers of workers tests are run with,
+// so that results do not depend on the cores of the machine.
+var testWorkers = []int{1, 2, 7}
+
+// withWorkers sets the default number of workers to n
+// for the duration of t.
+func withWorkers(t testing.TB, n int) {
+	prev := workers()
+	t.Cleanup(func() { SetWorkers(prev) })
+	if err := SetWorkers(n); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestRun(t *testing.T) {
-	initTests()
-	err := Run(testCases)
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			withWorkers(t, n)
+			initTests()
+			err := Run(testCases)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range testCases {
+				if !e.(*dummy).done {
+					t.Fatal("task not executed")
+				}
+			}
+		})
+	}
+}
+
+func TestRun_singleWorker(t *testing.T) {
+	withWorkers(t, 1)
+	var order []int
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &recorder{i: i, order: &order}
+	}
+	stats, err := RunWithStats(tasks, Options{})
 	if err != nil {
 		t.Fatal(err)
 	}
-	for _, e := range testCases {
-		if !e.(*dummy).done {
-			t.Fatal("task not executed")
+	if len(stats.Workers) != 1 || stats.Workers[0].Tasks != len(tasks) {
+		t.Fatalf("expected all tasks on one worker, got %+v", stats.Workers)
+	}
+	for i, e := range order {
+		if e != i {
+			t.Fatalf("expected task %d at position %d, got %d", i, i, e)
 		}
 	}
 }
+
 func TestRunSync(t *testing.T) {
 	initTests()
-	err := runSync(testCases)
+	err := RunSync(testCases)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -98,7 +148,7 @@ func BenchmarkSync(b *testing.B) {
 	initTests()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		runSync(testCases)
+		RunSync(testCases)
 	}
 }
 
@@ -142,7 +192,6 @@ func TestGain(t *testing.T) {
 	prev := 1
 	// Bigger number to check.
 	var limit int = 1e6
-	pre := time.Now()
 	// Create as much tasks as number of cores.
 	d := int(limit / cores)
 	for i := 1; i < limit; i++ {
@@ -160,22 +209,14 @@ func TestGain(t *testing.T) {
 	// Do not forget last interval.
 	j := &job{start: prev, stop: limit}
 	tasks = append(tasks, Tasker(j))
-	// Run tasks in parallel using all cores.
-	err := Run(tasks)
-	if err != nil {
-		t.Fatal(err)
-	}
-	after := time.Now()
-	Δt1 := after.Sub(pre)
-
-	// Lets compare execution time using single core.
-	pre = time.Now()
-	results := make(map[int]bool)
-	for i := 1; i <= limit; i++ {
-		results[i] = isPrime(uint64(i))
-	}
-	after = time.Now()
-	Δt2 := after.Sub(pre)
+	// Run tasks in parallel using all cores and
+	// lets compare execution time using single core.
+	Δt1, Δt2, _ := CompareSerial(tasks, func() {
+		results := make(map[int]bool)
+		for i := 1; i <= limit; i++ {
+			results[i] = isPrime(uint64(i))
+		}
+	})
 	if Δt2 < Δt1 {
 		t.Error("using parallel takes more time")
 	}
@@ -185,3 +226,739 @@ func TestGain(t *testing.T) {
 	p := (100 * diff) / Δt2
 	t.Logf("gain: %9d%%\n", p)
 }
+
+type cancelable struct {
+	executed int32
+	canceled int32
+}
+
+func (c *cancelable) Execute() { atomic.AddInt32(&c.executed, 1) }
+
+func (c *cancelable) OnCancel() { atomic.AddInt32(&c.canceled, 1) }
+
+// gated is a cancelable executed once gate is closed.
+type gated struct {
+	*cancelable
+	gate <-chan struct{}
+}
+
+func (g gated) Execute() {
+	<-g.gate
+	g.cancelable.Execute()
+}
+
+// interrupted returns an interrupter followed by n cancelables
+// that wait for it, so that the queue can not be exhausted
+// before the signal is sent.
+func interrupted(c *chan<- os.Signal, n int) []Tasker {
+	gate := make(chan struct{})
+	tasks := []Tasker{TaskFunc(func() {
+		*c <- os.Interrupt
+		close(gate)
+	})}
+	for i := 0; i < n; i++ {
+		tasks = append(tasks, gated{&cancelable{}, gate})
+	}
+	return tasks
+}
+
+// interrupter simulates a SIGINT while the queue is being populated.
+type interrupter struct {
+	c *chan<- os.Signal
+}
+
+func (i interrupter) Execute() { *i.c <- os.Interrupt }
+
+// interrupterSignal simulates a given signal while the queue is
+// being populated.
+type interrupterSignal struct {
+	c   *chan<- os.Signal
+	sig os.Signal
+}
+
+func (i interrupterSignal) Execute() { *i.c <- i.sig }
+
+func TestRunWith_signals(t *testing.T) {
+	var sigChan chan<- os.Signal
+	var trapped []os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
+		sigChan = c
+		trapped = sig
+	}
+	defer func() { notifySignal = signal.Notify }()
+	tasks := []Tasker{interrupterSignal{&sigChan, syscall.SIGTERM}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	if err := Run(tasks); err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	if !reflect.DeepEqual(trapped, []os.Signal{os.Interrupt, syscall.SIGTERM}) {
+		t.Fatal("unexpected default signals", trapped)
+	}
+	trapped = nil
+	if err := RunWith(tasks[1:], Options{Signals: []os.Signal{syscall.SIGHUP}}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(trapped, []os.Signal{syscall.SIGHUP}) {
+		t.Fatal("unexpected signals", trapped)
+	}
+	trapped = nil
+	if err := RunWith(tasks[1:], Options{Signals: []os.Signal{}}); err != nil {
+		t.Fatal(err)
+	}
+	if trapped != nil {
+		t.Fatal("signals trapped with signal handling disabled")
+	}
+}
+
+func TestRun_cancelOnInterrupt(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			withWorkers(t, n)
+			tasks := interrupted(&sigChan, 1e2)
+			if err := Run(tasks); err != ErrTasksNotCompleted {
+				t.Fatal("expected ErrTasksNotCompleted, got", err)
+			}
+			var canceled int
+			for i, e := range tasks[1:] {
+				c := e.(gated).cancelable
+				if c.executed+c.canceled != 1 {
+					t.Fatalf("task %d executed %d times and canceled %d times", i, c.executed, c.canceled)
+				}
+				canceled += int(c.canceled)
+			}
+			if canceled == 0 {
+				t.Fatal("no task was canceled")
+			}
+		})
+	}
+}
+
+type canceler struct {
+	cancel context.CancelFunc
+}
+
+func (c canceler) Execute() { c.cancel() }
+
+func TestRunContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tasks := []Tasker{canceler{cancel}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	err := RunContext(ctx, tasks)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if err == ErrTasksNotCompleted {
+		t.Fatal("cancellation must be distinguishable from SIGINT")
+	}
+	var executed int
+	for _, e := range tasks[1:] {
+		executed += int(e.(*cancelable).executed)
+	}
+	if executed == len(tasks)-1 {
+		t.Fatal("all tasks executed after cancellation")
+	}
+}
+
+func TestSetWorkers(t *testing.T) {
+	prev := workers()
+	defer SetWorkers(prev)
+	if err := SetWorkers(0); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+	if workers() != prev {
+		t.Fatal("invalid value changed number of workers")
+	}
+	if err := SetWorkers(3); err != nil {
+		t.Fatal(err)
+	}
+	if workers() != 3 {
+		t.Fatalf("expected 3 workers, got %d", workers())
+	}
+	initTests()
+	if err := Run(testCases); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range testCases {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed")
+		}
+	}
+}
+
+func TestRunWith_strategy(t *testing.T) {
+	for _, s := range []Strategy{StrategyChannels, StrategyWaitGroup} {
+		initTests()
+		if err := RunWith(testCases, Options{Strategy: s}); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testCases {
+			if !e.(*dummy).done {
+				t.Fatalf("strategy %d: task not executed", s)
+			}
+		}
+	}
+	if err := RunWith(testCases, Options{Strategy: 42}); err != ErrInvalidStrategy {
+		t.Fatal("expected ErrInvalidStrategy, got", err)
+	}
+}
+
+func TestRunWith(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7} {
+		initTests()
+		if err := RunWith(testCases, Options{Workers: n}); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testCases {
+			if !e.(*dummy).done {
+				t.Fatalf("%d workers: task not executed", n)
+			}
+		}
+	}
+	for _, depth := range []int{1, 1e3} {
+		initTests()
+		if err := RunWith(testCases, Options{QueueDepth: depth}); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testCases {
+			if !e.(*dummy).done {
+				t.Fatalf("queue depth %d: task not executed", depth)
+			}
+		}
+	}
+	if err := RunWith(testCases, Options{QueueDepth: -1}); err != ErrInvalidQueueDepth {
+		t.Fatal("expected ErrInvalidQueueDepth, got", err)
+	}
+	if err := RunWith(testCases, Options{Workers: -1}); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+}
+
+func TestRun_panic(t *testing.T) {
+	tasks := []Tasker{panicker{}}
+	for i := 0; i < 1e1; i++ {
+		tasks = append(tasks, &dummy{}, panicker{})
+	}
+	err := Run(tasks)
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	if pe.Value != "boom" {
+		t.Fatalf("unexpected panic value %v", pe.Value)
+	}
+	for _, e := range tasks {
+		if d, ok := e.(*dummy); ok && !d.done {
+			t.Fatal("task not executed after a panic")
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = Tasker(&sleeper{d: 10 * time.Millisecond})
+	}
+	pre := time.Now()
+	if err := RunTimeout(tasks, 30*time.Millisecond); err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	if elapsed := time.Since(pre); elapsed > 500*time.Millisecond {
+		t.Fatalf("run did not stop after timeout, took %s", elapsed)
+	}
+	if err := RunTimeout(tasks[:1], time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunWith_taskTimeout(t *testing.T) {
+	hung := &sleeper{d: time.Hour}
+	tasks := []Tasker{hung}
+	for i := 0; i < 1e1; i++ {
+		tasks = append(tasks, &dummy{})
+	}
+	err := RunWith(tasks, Options{TaskTimeout: 50 * time.Millisecond})
+	if !errors.Is(err, ErrTaskTimeout) {
+		t.Fatal("expected ErrTaskTimeout, got", err)
+	}
+	for _, e := range tasks[1:] {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed after a timeout")
+		}
+	}
+}
+
+func TestRunFuncs(t *testing.T) {
+	results := make([]bool, 1e2)
+	fns := make([]func(), len(results))
+	for i := range fns {
+		i := i
+		fns[i] = func() { results[i] = isPrime(uint64(i)) }
+	}
+	if err := RunFuncs(fns); err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range results {
+		if r != isPrime(uint64(i)) {
+			t.Fatalf("wrong result for %d", i)
+		}
+	}
+	var done bool
+	if err := Run([]Tasker{TaskFunc(func() { done = true })}); err != nil {
+		t.Fatal(err)
+	}
+	if !done {
+		t.Fatal("TaskFunc not executed")
+	}
+}
+
+func TestRunWith_onProgress(t *testing.T) {
+	initTests()
+	var calls int
+	progress := func(done, total int, eta time.Duration) {
+		calls++
+		if done == total && eta != 0 {
+			t.Fatalf("expected no remaining time at the end, got %s", eta)
+		}
+		if done != calls {
+			t.Fatalf("expected done %d, got %d", calls, done)
+		}
+		if total != len(testCases) {
+			t.Fatalf("expected total %d, got %d", len(testCases), total)
+		}
+	}
+	if err := RunWith(testCases, Options{OnProgress: progress}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != len(testCases) {
+		t.Fatalf("expected %d progress calls, got %d", len(testCases), calls)
+	}
+}
+
+func TestRunWith_onComplete(t *testing.T) {
+	for _, w := range testWorkers {
+		initTests()
+		tasks := append([]Tasker{panicker{}}, testCases...)
+		var calls int
+		complete := func(t Tasker) error {
+			calls++
+			if t == tasks[10] {
+				return errOdd
+			}
+			if t == tasks[20] {
+				panic("complete")
+			}
+			return nil
+		}
+		err := RunWith(tasks, Options{Workers: w, OnComplete: complete, ErrorMode: ErrCollectAll})
+		var all *Errors
+		if !errors.As(err, &all) {
+			t.Fatal("expected *Errors, got", err)
+		}
+		var p *PanicError
+		if !errors.As(all.Tasks[0], &p) || all.Tasks[10] != errOdd || !errors.As(all.Tasks[20], &p) {
+			t.Fatalf("workers=%d: unexpected errors %v", w, err)
+		}
+		// Failed Taskers are not completed.
+		if calls != len(testCases) {
+			t.Fatalf("workers=%d: expected %d calls, got %d", w, len(testCases), calls)
+		}
+	}
+	initTests()
+	err := RunWith(testCases, Options{Workers: 1, FailFast: true, OnComplete: func(Tasker) error { return errOdd }})
+	if te, ok := err.(*TaskError); !ok || te.Index != 0 || te.Err != errOdd {
+		t.Fatal("expected a *TaskError for task 0, got", err)
+	}
+	if testCases[1].(*dummy).done {
+		t.Fatal("run did not fail fast on OnComplete error")
+	}
+}
+
+func TestRunWith_eta(t *testing.T) {
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = &sleeper{10 * time.Millisecond}
+	}
+	var first time.Duration
+	progress := func(done, total int, eta time.Duration) {
+		if done == 1 {
+			first = eta
+		}
+	}
+	if err := RunWith(tasks, Options{Workers: 1, OnProgress: progress}); err != nil {
+		t.Fatal(err)
+	}
+	// 9 tasks of 10ms remain after the first.
+	if first < 80*time.Millisecond || first > 200*time.Millisecond {
+		t.Fatalf("expected an estimate of about 90ms, got %s", first)
+	}
+}
+
+func TestRun_concurrent(t *testing.T) {
+	batches := make([][]Tasker, 8)
+	errs := make(chan error, len(batches))
+	for i := range batches {
+		batches[i] = make([]Tasker, 1e2)
+		for j := range batches[i] {
+			batches[i][j] = &counter{}
+		}
+		go func(b []Tasker) { errs <- Run(b) }(batches[i])
+	}
+	for range batches {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, b := range batches {
+		for _, e := range b {
+			if n := e.(*counter).n; n != 1 {
+				t.Fatalf("batch %d: task executed %d times", i, n)
+			}
+		}
+	}
+}
+
+func TestRun_empty(t *testing.T) {
+	for _, jobs := range [][]Tasker{nil, {}} {
+		for _, opts := range []Options{{}, {Timeout: time.Hour}, {Strategy: StrategyWaitGroup}} {
+			errc := make(chan error, 1)
+			go func() { errc <- RunWith(jobs, opts) }()
+			select {
+			case err := <-errc:
+				if err != nil {
+					t.Fatal(err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("run of %#v with %+v did not return", jobs, opts)
+			}
+		}
+		if err := RunSync(jobs); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRun_nilTask(t *testing.T) {
+	initTests()
+	tasks := append([]Tasker{nil}, testCases...)
+	tasks = append(tasks, nil)
+	if err := Run(tasks); !errors.Is(err, ErrNilTask) {
+		t.Fatal("expected ErrNilTask, got", err)
+	}
+	for _, e := range testCases {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed")
+		}
+	}
+	if err := RunSync([]Tasker{nil}); !errors.Is(err, ErrNilTask) {
+		t.Fatal("expected ErrNilTask, got", err)
+	}
+}
+
+func TestMustRun(t *testing.T) {
+	initTests()
+	MustRun(testCases)
+	defer func() {
+		var p *PanicError
+		if err, ok := recover().(error); !ok || !errors.As(err, &p) {
+			t.Fatal("expected a panic with a *PanicError, got", err)
+		}
+	}()
+	MustRun([]Tasker{panicker{}})
+}
+
+// stuck blocks until release is closed.
+type stuck struct {
+	release chan struct{}
+}
+
+func (s stuck) Execute() { <-s.release }
+
+func TestRunWith_stallTimeout(t *testing.T) {
+	before := goroutines()
+	s := stuck{make(chan struct{})}
+	tasks := []Tasker{&dummy{}, s, &dummy{}}
+	err := RunWith(tasks, Options{Workers: 1, StallTimeout: 20 * time.Millisecond})
+	if !errors.Is(err, ErrStalled) {
+		t.Fatal("expected ErrStalled, got", err)
+	}
+	tasks = []Tasker{s, s, &dummy{}, &dummy{}, &dummy{}}
+	err = RunWith(tasks, Options{Workers: 2, StallTimeout: 20 * time.Millisecond})
+	if !errors.Is(err, ErrStalled) {
+		t.Fatal("expected ErrStalled, got", err)
+	}
+	close(s.release)
+	if left := leaked(before); len(left) > 0 {
+		t.Fatalf("goroutines leaked:\n%s", strings.Join(left, "\n\n"))
+	}
+	initTests()
+	if err := RunWith(testCases, Options{StallTimeout: time.Second}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// goroutines returns the stacks of goroutines running functions of
+// the package, or started by them, keyed by goroutine ID. Goroutines
+// that exist already, e.g. abandoned by earlier tests, keep their ID.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	pkg := reflect.TypeOf(dummy{}).PkgPath() + "."
+	stacks := make(map[string]string)
+	for _, g := range strings.Split(string(buf), "\n\n") {
+		if !strings.Contains(g, pkg) {
+			continue
+		}
+		// The header is "goroutine ID [state]:".
+		if f := strings.Fields(g); len(f) > 1 {
+			stacks[f[1]] = g
+		}
+	}
+	return stacks
+}
+
+// leaked waits for goroutines of the package that are not in
+// before to exit, returning the stacks of the ones left.
+func leaked(before map[string]string) []string {
+	var left []string
+	for i := 0; i < 100; i++ {
+		left = left[:0]
+		for id, g := range goroutines() {
+			if _, ok := before[id]; !ok {
+				left = append(left, g)
+			}
+		}
+		if len(left) == 0 {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return left
+}
+
+func TestRun_noLeak(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	runs := map[string]func() []Tasker{
+		"clean": func() []Tasker {
+			initTests()
+			return testCases
+		},
+		"empty":       func() []Tasker { return nil },
+		"interrupted": func() []Tasker { return interrupted(&sigChan, 1e2) },
+	}
+	for name, tasks := range runs {
+		for _, n := range testWorkers {
+			t.Run(fmt.Sprintf("%s/workers=%d", name, n), func(t *testing.T) {
+				before := goroutines()
+				err := RunWith(tasks(), Options{Workers: n})
+				if name == "interrupted" && err != ErrTasksNotCompleted {
+					t.Fatal("expected ErrTasksNotCompleted, got", err)
+				} else if name != "interrupted" && err != nil {
+					t.Fatal(err)
+				}
+				if left := leaked(before); len(left) > 0 {
+					t.Fatalf("goroutines leaked:\n%s", strings.Join(left, "\n\n"))
+				}
+			})
+		}
+	}
+}
+
+func TestRun_signalStopped(t *testing.T) {
+	// guard keeps SIGUSR1 from terminating the process.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, syscall.SIGUSR1)
+	defer signal.Stop(guard)
+	var trapped []chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) {
+		trapped = append(trapped, c)
+		signal.Notify(c, sig...)
+	}
+	defer func() { notifySignal = signal.Notify }()
+	for _, n := range testWorkers {
+		initTests()
+		if err := RunWith(testCases, Options{Workers: n, Signals: []os.Signal{syscall.SIGUSR1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	<-guard
+	// Leave time to relay it to other channels.
+	time.Sleep(10 * time.Millisecond)
+	for _, c := range trapped {
+		if len(c) != 0 {
+			t.Fatal("signal relayed to a completed run")
+		}
+	}
+}
+
+// procsRecorder records GOMAXPROCS while executed.
+type procsRecorder struct {
+	procs int
+}
+
+func (p *procsRecorder) Execute() { p.procs = runtime.GOMAXPROCS(0) }
+
+func TestRunWith_gomaxprocs(t *testing.T) {
+	prev := runtime.GOMAXPROCS(0)
+	for _, s := range []Strategy{StrategyChannels, StrategyWaitGroup} {
+		p := &procsRecorder{}
+		if err := RunWith([]Tasker{p}, Options{GOMAXPROCS: prev + 3, Strategy: s}); err != nil {
+			t.Fatal(err)
+		}
+		if p.procs != prev+3 {
+			t.Fatalf("expected GOMAXPROCS %d during run, got %d", prev+3, p.procs)
+		}
+		if procs := runtime.GOMAXPROCS(0); procs != prev {
+			t.Fatalf("expected GOMAXPROCS %d restored, got %d", prev, procs)
+		}
+	}
+	if err := RunWith(testCases, Options{GOMAXPROCS: -1}); err != ErrInvalidProcs {
+		t.Fatal("expected ErrInvalidProcs, got", err)
+	}
+}
+
+// interruptStuck simulates a SIGINT then blocks until release is closed.
+type interruptStuck struct {
+	interrupter
+	stuck
+}
+
+func (i interruptStuck) Execute() {
+	i.interrupter.Execute()
+	i.stuck.Execute()
+}
+
+func TestRunWith_shutdownGrace(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	s := stuck{make(chan struct{})}
+	defer close(s.release)
+	tasks := []Tasker{interruptStuck{interrupter{&sigChan}, s}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- RunWith(tasks, Options{Workers: 1, ShutdownGrace: 20 * time.Millisecond}) }()
+	select {
+	case err := <-errc:
+		if err != ErrTasksNotCompleted {
+			t.Fatal("expected ErrTasksNotCompleted, got", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("run did not return after the grace period")
+	}
+}
+
+func TestRunWith_shutdownGraceNoLeak(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	before := goroutines()
+	s := stuck{make(chan struct{})}
+	tasks := []Tasker{interruptStuck{interrupter{&sigChan}, s}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &slowCancelable{d: 2 * time.Millisecond})
+	}
+	opts := Options{Workers: 2, QueueDepth: 10, ShutdownGrace: 10 * time.Millisecond}
+	if err := RunWith(tasks, opts); err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	var executed int32
+	for _, e := range tasks[1:] {
+		executed += atomic.LoadInt32(&e.(*slowCancelable).executed)
+	}
+	close(s.release)
+	if left := leaked(before); len(left) > 0 {
+		t.Fatalf("goroutines leaked:\n%s", strings.Join(left, "\n\n"))
+	}
+	// Taskers buffered on return reach the cancel path
+	// only as workers take them, wait for all of them.
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		var pending bool
+		for _, e := range tasks[1:] {
+			c := e.(*slowCancelable)
+			if atomic.LoadInt32(&c.executed)+atomic.LoadInt32(&c.canceled) == 0 {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Buffered Taskers are not started once the run returned.
+	for i, e := range tasks[1:] {
+		c := e.(*slowCancelable)
+		n, m := atomic.LoadInt32(&c.executed), atomic.LoadInt32(&c.canceled)
+		if n+m != 1 {
+			t.Fatalf("task %d executed %d times and canceled %d times", i, n, m)
+		}
+		executed -= n
+	}
+	// At most the Tasker running on return completes later.
+	if executed < -1 {
+		t.Fatalf("%d tasks started after the run returned", -executed)
+	}
+}
+
+// slowCancelable is a cancelable taking d to execute.
+type slowCancelable struct {
+	cancelable
+	d time.Duration
+}
+
+func (c *slowCancelable) Execute() {
+	time.Sleep(c.d)
+	c.cancelable.Execute()
+}
+
+func TestRunWith_fewerTasksThanWorkers(t *testing.T) {
+	withWorkers(t, 64)
+	l := &eventLogger{events: make(map[string]int)}
+	c := &counter{}
+	s, err := RunWithStats([]Tasker{c, c, c}, Options{Logger: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.n != 3 {
+		t.Fatalf("expected 3 executions, got %d", c.n)
+	}
+	if len(s.Workers) != 3 || l.events["parallel: worker started"] != 3 {
+		t.Fatalf("expected 3 workers, got %d started of %d", l.events["parallel: worker started"], len(s.Workers))
+	}
+}
+
+func TestRunWith_lockWorkerThreads(t *testing.T) {
+	for _, n := range testWorkers {
+		initTests()
+		if err := RunWith(testCases, Options{Workers: n, LockWorkerThreads: true}); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testCases {
+			if !e.(*dummy).done {
+				t.Fatal("task not executed")
+			}
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+// Package paralleltest provides helpers to test code
+// running Taskers with package parallel.
+package paralleltest
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/eraclitux/parallel"
+)
+
+// Counted is a Tasker counting executions of the wrapped one.
+// Other interfaces of the wrapped Tasker, e.g. Canceler, are hidden.
+type Counted struct {
+	parallel.Tasker
+	n int32
+}
+
+func (c *Counted) Execute() {
+	atomic.AddInt32(&c.n, 1)
+	c.Tasker.Execute()
+}
+
+// Executions returns how many times the Tasker has been executed.
+func (c *Counted) Executions() int {
+	return int(atomic.LoadInt32(&c.n))
+}
+
+// Count wraps every Tasker of jobs in a Counted.
+func Count(jobs []parallel.Tasker) []parallel.Tasker {
+	counted := make([]parallel.Tasker, len(jobs))
+	for i, j := range jobs {
+		counted[i] = &Counted{Tasker: j}
+	}
+	return counted
+}
+
+// AssertAllExecuted fails t if a Tasker of jobs did not run or, for
+// the ones wrapped in Counted, ran more than once. isDone, if not nil,
+// reports whether a Tasker ran, it receives the wrapped Tasker of a
+// Counted. Taskers that are not Counted need isDone.
+func AssertAllExecuted(t testing.TB, jobs []parallel.Tasker, isDone func(parallel.Tasker) bool) {
+	t.Helper()
+	for i, j := range jobs {
+		c, counted := j.(*Counted)
+		if counted {
+			if n := c.Executions(); n != 1 {
+				t.Errorf("task %d executed %d times", i, n)
+				continue
+			}
+			j = c.Tasker
+		}
+		if isDone == nil {
+			if !counted {
+				t.Errorf("task %d is not counted and isDone is nil", i)
+			}
+			continue
+		}
+		if !isDone(j) {
+			t.Errorf("task %d not executed", i)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package paralleltest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/eraclitux/parallel"
+)
+
+// recorder records failures instead of failing the test.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+type flag struct {
+	done bool
+}
+
+func (f *flag) Execute() { f.done = true }
+
+func isDone(t parallel.Tasker) bool { return t.(*flag).done }
+
+func TestAssertAllExecuted(t *testing.T) {
+	jobs := Count([]parallel.Tasker{&flag{}, &flag{}, &flag{}})
+	if err := parallel.Run(jobs); err != nil {
+		t.Fatal(err)
+	}
+	AssertAllExecuted(t, jobs, isDone)
+	AssertAllExecuted(t, jobs, nil)
+	r := &recorder{TB: t}
+	// Executed twice.
+	jobs[0].Execute()
+	// Not executed, the count is not enough.
+	jobs[1].(*Counted).Tasker.(*flag).done = false
+	AssertAllExecuted(r, jobs, isDone)
+	want := []string{"task 0 executed 2 times", "task 1 not executed"}
+	if fmt.Sprint(r.errors) != fmt.Sprint(want) {
+		t.Fatalf("expected %q, got %q", want, r.errors)
+	}
+	r.errors = nil
+	AssertAllExecuted(r, []parallel.Tasker{&flag{}}, nil)
+	if len(r.errors) != 1 {
+		t.Fatal("expected an uncounted task to fail without isDone, got", r.errors)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "time"
+
+// plan fills r as if tasks were executed in order by its workers,
+// every one taking the next Tasker as soon as it's free, without
+// executing them, see Options.DryRun. Only stats read the plan, Taskers
+// are not reported as executed. cost, if not nil, estimates
+// durations, otherwise Taskers take one unit of time each and no
+// duration is reported.
+func (r *report) plan(tasks []indexedTask, cost func(Tasker) time.Duration) {
+	// free is when every worker is done with its Taskers.
+	free := make([]time.Duration, len(r.workers))
+	r.planned = make([]bool, len(r.tasks))
+	for _, t := range tasks {
+		w := 0
+		for i := range free {
+			if free[i] < free[w] {
+				w = i
+			}
+		}
+		var d time.Duration
+		if cost != nil {
+			d = cost(t.Tasker)
+			free[w] += d
+		} else {
+			free[w]++
+		}
+		r.planned[t.index] = true
+		r.assigned[t.index] = w
+		r.durations[t.index] = d
+		r.workers[w].Tasks++
+		r.workers[w].Busy += d
+		r.finished++
+		r.busy += d
+	}
+	if cost == nil {
+		return
+	}
+	for _, f := range free {
+		r.elapsed = max(r.elapsed, f)
+	}
+}
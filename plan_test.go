@@ -0,0 +1,64 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunWith_dryRun(t *testing.T) {
+	initTests()
+	stats, err := RunWithStats(testCases, Options{Workers: 4, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range testCases {
+		if e.(*dummy).done {
+			t.Fatal("task executed in a dry run")
+		}
+		// Equal costs are dealt in turn.
+		if w := stats.Tasks[i].Worker; w != i%4 {
+			t.Fatalf("task %d: expected worker %d, got %d", i, i%4, w)
+		}
+	}
+	for _, w := range stats.Workers {
+		if w.Tasks != 25 {
+			t.Fatalf("expected 25 tasks per worker, got %+v", stats.Workers)
+		}
+	}
+
+	// Heavy Taskers first leave room to light ones at the end.
+	tasks := []Tasker{&heavy{w: 1}, &heavy{w: 1}, &heavy{w: 2}, &heavy{w: 2}, &heavy{w: 4}}
+	cost := func(t Tasker) time.Duration { return time.Duration(t.(*heavy).w) * time.Second }
+	stats, err = RunWithStats(tasks, Options{Workers: 2, DryRun: true, Cost: cost})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Elapsed != 7*time.Second {
+		t.Fatal("expected 7s in jobs order, got", stats.Elapsed)
+	}
+	stats, err = RunWithStats(tasks, Options{Workers: 2, DryRun: true, Cost: cost, Weighted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Elapsed != 5*time.Second {
+		t.Fatal("expected 5s by decreasing weight, got", stats.Elapsed)
+	}
+	if stats.Tasks[4].Worker != 0 || stats.Tasks[4].Duration != 4*time.Second {
+		t.Fatalf("unexpected plan of the heaviest task: %+v", stats.Tasks[4])
+	}
+}
+
+func TestRunCompleted_dryRun(t *testing.T) {
+	initTests()
+	completed, err := RunCompleted(testCases, Options{Workers: 4, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != 0 {
+		t.Fatalf("expected no completed task in a dry run, got %d", len(completed))
+	}
+}
@@ -0,0 +1,522 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrPoolClosed says that a Pool has been closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// Pool is a set of workers kept alive among runs,
+// avoiding to start and stop goroutines for every batch.
+// It's safe for concurrent use.
+type Pool struct {
+	queue chan poolTask
+	// out feeds workers, it's queue unless
+	// a dispatcher reorders Taskers.
+	out chan poolTask
+	// mu guards closed, Submit holds it for reading
+	// while feeding queue.
+	mu      sync.RWMutex
+	closed  bool
+	workers sync.WaitGroup
+	// active are batches being executed.
+	activeMu sync.Mutex
+	active   map[*batch]struct{}
+	// gate, if not nil, blocks workers
+	// until it's closed by Resume.
+	gateMu sync.Mutex
+	gate   chan struct{}
+	// running is the number of Taskers being executed,
+	// accessed atomically.
+	running int32
+	// in feeds Taskers to forward.
+	in chan Tasker
+	// barrier hands to Wait the Taskers
+	// received by forward since the previous Wait.
+	barrier chan chan *batch
+	quit    chan struct{}
+	// halt is closed by ShutdownNow, workers then
+	// collect Taskers they take in unstarted.
+	halt        chan struct{}
+	haltOnce    sync.Once
+	unstartedMu sync.Mutex
+	unstarted   []Tasker
+	// metrics, if set, counts Taskers.
+	metrics atomic.Pointer[Metrics]
+}
+
+// poolTask is a Tasker along with the batch it belongs to.
+type poolTask struct {
+	indexedTask
+	b *batch
+}
+
+// batch tracks Taskers of a single Submit.
+type batch struct {
+	r        *report
+	wg       sync.WaitGroup
+	once     sync.Once
+	canceled chan struct{}
+	// errs collects errors when r is nil,
+	// for Taskers from SubmitChan.
+	errs errCollector
+	// deliver, if not nil, receives the outcome of
+	// every Tasker in place of r and errs.
+	deliver func(t Tasker, err error)
+	// running maps indices of CtxTaskers being
+	// executed to the cancel func of their context.
+	runMu   sync.Mutex
+	running map[int]context.CancelFunc
+}
+
+// record stores the outcome of t.
+func (b *batch) record(t indexedTask, err error) {
+	if b.deliver != nil {
+		b.deliver(t.Tasker, err)
+		return
+	}
+	i := t.index
+	if b.r == nil {
+		if err != nil {
+			b.errs.add(&TaskError{Index: i, Task: t.Tasker, Err: err})
+		}
+		return
+	}
+	// Every Tasker writes its own slots.
+	b.r.errs[i] = err
+	b.r.executed[i] = true
+}
+
+// execute executes t, a CtxTasker receives a context
+// that CancelTask can cancel.
+func (b *batch) execute(t indexedTask) error {
+	ctx, cancelDeadline, err := withDeadline(context.Background(), t.Tasker)
+	defer cancelDeadline()
+	if err != nil {
+		return err
+	}
+	if _, ok := t.Tasker.(ctxExecutor); !ok {
+		return execute(t.Tasker)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	b.runMu.Lock()
+	if b.running == nil {
+		b.running = make(map[int]context.CancelFunc)
+	}
+	b.running[t.index] = cancel
+	b.runMu.Unlock()
+	defer func() {
+		b.runMu.Lock()
+		delete(b.running, t.index)
+		b.runMu.Unlock()
+		cancel()
+	}()
+	return executeContext(ctx, t.Tasker)
+}
+
+// cancelTask cancels the context of the i-th
+// Tasker if it's running.
+func (b *batch) cancelTask(i int) bool {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	cancel, ok := b.running[i]
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+func (b *batch) cancel() {
+	b.once.Do(func() { close(b.canceled) })
+}
+
+func (b *batch) isCanceled() bool {
+	select {
+	case <-b.canceled:
+		return true
+	default:
+		return false
+	}
+}
+
+// NewPool starts a Pool with the given number of workers,
+// zero or less meaning the package default.
+func NewPool(workers int) *Pool {
+	p := newPool(workers)
+	p.out = p.queue
+	p.start()
+	return p
+}
+
+func newPool(workers int) *Pool {
+	n := workersOptions(workers).workers()
+	return &Pool{
+		queue:   make(chan poolTask, n),
+		active:  make(map[*batch]struct{}),
+		in:      make(chan Tasker),
+		barrier: make(chan chan *batch),
+		quit:    make(chan struct{}),
+		halt:    make(chan struct{}),
+	}
+}
+
+// start starts workers and the goroutine feeding
+// them Taskers from SubmitChan.
+func (p *Pool) start() {
+	n := cap(p.queue)
+	p.workers.Add(n)
+	for i := 0; i < n; i++ {
+		go p.work()
+	}
+	go p.forward()
+}
+
+func (p *Pool) work() {
+	defer p.workers.Done()
+	for {
+		p.waitResumed()
+		t, ok := <-p.out
+		if !ok {
+			return
+		}
+		// Pause may have been called while
+		// the worker was waiting for t.
+		p.waitResumed()
+		if p.halted() {
+			p.drop([]indexedTask{t.indexedTask})
+			t.b.wg.Done()
+			continue
+		}
+		if t.b.isCanceled() {
+			cancelRemaining([]indexedTask{t.indexedTask})
+			t.b.wg.Done()
+			continue
+		}
+		m := p.meter()
+		if m != nil {
+			m.Started()
+		}
+		atomic.AddInt32(&p.running, 1)
+		err := t.b.execute(t.indexedTask)
+		atomic.AddInt32(&p.running, -1)
+		if m != nil {
+			m.Done(err != nil)
+		}
+		t.b.record(t.indexedTask, err)
+		t.b.wg.Done()
+	}
+}
+
+// Submit executes jobs on the Pool workers blocking until all of them
+// are done. Errors are returned as in Run.
+func (p *Pool) Submit(jobs []Tasker) error {
+	p.mu.RLock()
+	if p.closed {
+		p.mu.RUnlock()
+		return ErrPoolClosed
+	}
+	b := &batch{r: newReport(jobs), canceled: make(chan struct{})}
+	p.activeMu.Lock()
+	p.active[b] = struct{}{}
+	p.activeMu.Unlock()
+	defer func() {
+		p.activeMu.Lock()
+		delete(p.active, b)
+		p.activeMu.Unlock()
+	}()
+	b.wg.Add(len(jobs))
+feed:
+	for i, j := range jobs {
+		select {
+		case p.queue <- poolTask{indexedTask{i, j}, b}:
+			p.submitted()
+		case <-b.canceled:
+			remaining := make([]indexedTask, 0, len(jobs)-i)
+			for k := i; k < len(jobs); k++ {
+				remaining = append(remaining, indexedTask{k, jobs[k]})
+			}
+			if p.halted() {
+				p.drop(remaining)
+			} else {
+				cancelRemaining(remaining)
+			}
+			b.wg.Add(-len(remaining))
+			break feed
+		}
+	}
+	p.mu.RUnlock()
+	b.wg.Wait()
+	if b.isCanceled() {
+		b.r.err = ErrTasksNotCompleted
+	}
+	return b.r.error()
+}
+
+// SubmitCtx is like Submit but Taskers receive a context
+// that is done when CancelTask is called with their index.
+func (p *Pool) SubmitCtx(jobs []CtxTasker) error {
+	tasks := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		if j != nil {
+			tasks[i] = ctxTask{j}
+		}
+	}
+	return p.Submit(tasks)
+}
+
+// PoolResult is the outcome of a Tasker
+// submitted with SubmitAsync.
+type PoolResult struct {
+	Task Tasker
+	// UserData is the value passed to SubmitAsync.
+	UserData interface{}
+	// Err is the error of the Tasker, e.g. a *PanicError.
+	Err error
+}
+
+// SubmitAsync queues t without waiting for it to complete, its
+// outcome is sent on results along with the opaque userdata, so that
+// results can be matched to their context without index bookkeeping.
+// The send happens on the worker that executed t: results must be
+// read, or buffered, for workers to keep going.
+func (p *Pool) SubmitAsync(t Tasker, userdata interface{}, results chan<- PoolResult) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolClosed
+	}
+	b := &batch{
+		canceled: make(chan struct{}),
+		deliver: func(t Tasker, err error) {
+			results <- PoolResult{Task: t, UserData: userdata, Err: err}
+		},
+	}
+	b.wg.Add(1)
+	p.queue <- poolTask{indexedTask{0, t}, b}
+	p.submitted()
+	return nil
+}
+
+// SubmitChan returns a channel that feeds Taskers to the Pool
+// workers as they are sent, without batching them. Use Wait to
+// block until they are done. The channel is never closed, Taskers
+// sent after Close are not executed and the send blocks forever.
+func (p *Pool) SubmitChan() chan<- Tasker {
+	return p.in
+}
+
+// Wait blocks until Taskers sent on SubmitChan since the previous
+// Wait are done and returns their errors joined, each a *TaskError
+// whose Index counts Taskers sent since the previous Wait. With a
+// single goroutine calling Wait these are all Taskers sent so far.
+// It returns nil once the Pool has been closed.
+func (p *Pool) Wait() error {
+	ack := make(chan *batch)
+	select {
+	case p.barrier <- ack:
+	case <-p.quit:
+		return nil
+	}
+	b := <-ack
+	b.wg.Wait()
+	return b.errs.join(nil)
+}
+
+// forward feeds Taskers from SubmitChan to workers.
+func (p *Pool) forward() {
+	b := &batch{canceled: make(chan struct{})}
+	// i counts Taskers received for b so far.
+	var i int
+	for {
+		select {
+		case t := <-p.in:
+			p.mu.RLock()
+			if !p.closed {
+				b.wg.Add(1)
+				p.queue <- poolTask{indexedTask{i, t}, b}
+				p.submitted()
+			}
+			p.mu.RUnlock()
+			i++
+		case ack := <-p.barrier:
+			// Taskers received so far belong to b,
+			// later ones to a new batch.
+			ack <- b
+			b = &batch{canceled: make(chan struct{})}
+			i = 0
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// SetMetrics makes the Pool count its Taskers with m,
+// nil stops counting. It can be called at any time.
+func (p *Pool) SetMetrics(m Metrics) {
+	if m == nil {
+		p.metrics.Store(nil)
+		return
+	}
+	p.metrics.Store(&m)
+}
+
+// meter returns the Metrics of the Pool, nil if none.
+func (p *Pool) meter() Metrics {
+	if m := p.metrics.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// submitted counts a queued Tasker.
+func (p *Pool) submitted() {
+	if m := p.meter(); m != nil {
+		m.Submitted()
+	}
+}
+
+// Cancel stops in progress Submits: Taskers not yet started are
+// dropped, running ones are left to finish, then Submits return
+// ErrTasksNotCompleted. The Pool can be used again afterwards.
+func (p *Pool) Cancel() {
+	p.activeMu.Lock()
+	defer p.activeMu.Unlock()
+	for b := range p.active {
+		b.cancel()
+	}
+}
+
+// CancelTask cancels the context of the running CtxTasker
+// at position index of in progress SubmitCtx calls, other
+// Taskers keep going. It reports whether a Tasker was signaled.
+// The CtxTasker must return on its own once its context is done.
+func (p *Pool) CancelTask(index int) bool {
+	p.activeMu.Lock()
+	defer p.activeMu.Unlock()
+	var found bool
+	for b := range p.active {
+		if b.cancelTask(index) {
+			found = true
+		}
+	}
+	return found
+}
+
+// Close waits for submitted Taskers to complete and stops the
+// workers. Subsequent Submits return ErrPoolClosed.
+func (p *Pool) Close() {
+	// Paused workers would never drain the queue, and Submits
+	// blocked feeding it hold mu.
+	p.Resume()
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.queue)
+	close(p.quit)
+	p.mu.Unlock()
+	p.workers.Wait()
+}
+
+// Shutdown is Close: Submits and SubmitAsyncs after it return
+// ErrPoolClosed, Taskers already submitted are executed and waited
+// for. See ShutdownNow to drop them instead.
+func (p *Pool) Shutdown() {
+	p.Close()
+}
+
+// ShutdownNow closes the Pool as Shutdown does, but Taskers submitted
+// and not yet started are not executed: workers wait only for running
+// ones. It returns Taskers that were not started, in no particular
+// order, after calling OnCancel on Cancelers among them. Submits in
+// progress return ErrTasksNotCompleted. CtxTaskers of SubmitCtx are
+// not Taskers and are not returned. Calling it on a closed Pool
+// returns nil.
+func (p *Pool) ShutdownNow() []Tasker {
+	p.mu.RLock()
+	closed := p.closed
+	p.mu.RUnlock()
+	if closed {
+		return nil
+	}
+	p.haltOnce.Do(func() { close(p.halt) })
+	// Submits in progress stop feeding queue,
+	// so that Close can take mu.
+	p.Cancel()
+	p.Close()
+	p.unstartedMu.Lock()
+	defer p.unstartedMu.Unlock()
+	unstarted := p.unstarted
+	p.unstarted = nil
+	return unstarted
+}
+
+// halted reports whether ShutdownNow has been called.
+func (p *Pool) halted() bool {
+	select {
+	case <-p.halt:
+		return true
+	default:
+		return false
+	}
+}
+
+// drop cancels Taskers that ShutdownNow returns.
+func (p *Pool) drop(jobs []indexedTask) {
+	cancelRemaining(jobs)
+	p.unstartedMu.Lock()
+	defer p.unstartedMu.Unlock()
+	for _, j := range jobs {
+		if _, ok := j.Tasker.(ctxTask); !ok {
+			p.unstarted = append(p.unstarted, j.Tasker)
+		}
+	}
+}
+
+// Active returns the number of Taskers being executed.
+// It's cheap enough to be polled frequently.
+func (p *Pool) Active() int {
+	return int(atomic.LoadInt32(&p.running))
+}
+
+// Pause stops workers from taking new Taskers, the ones being
+// executed are left to finish. Submits in progress block until
+// Resume is called. Pausing a paused Pool does nothing.
+func (p *Pool) Pause() {
+	p.gateMu.Lock()
+	defer p.gateMu.Unlock()
+	if p.gate == nil {
+		p.gate = make(chan struct{})
+	}
+}
+
+// Resume lets workers of a paused Pool take Taskers again.
+// Close resumes the Pool too.
+func (p *Pool) Resume() {
+	p.gateMu.Lock()
+	defer p.gateMu.Unlock()
+	if p.gate != nil {
+		close(p.gate)
+		p.gate = nil
+	}
+}
+
+// waitResumed blocks while p is paused.
+func (p *Pool) waitResumed() {
+	p.gateMu.Lock()
+	gate := p.gate
+	p.gateMu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	p := NewPool(3)
+	for i := 0; i < 10; i++ {
+		initTests()
+		if err := p.Submit(testCases); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range testCases {
+			if !e.(*dummy).done {
+				t.Fatal("task not executed")
+			}
+		}
+	}
+	err := p.Submit([]Tasker{&dummy{}, panicker{}})
+	var pe *PanicError
+	if !errors.As(err, &pe) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	p.Close()
+	if err := p.Submit(testCases); err != ErrPoolClosed {
+		t.Fatal("expected ErrPoolClosed, got", err)
+	}
+	// Closing twice is harmless.
+	p.Close()
+}
+
+func TestPool_concurrentSubmit(t *testing.T) {
+	p := NewPool(0)
+	defer p.Close()
+	batches := make([][]Tasker, 4)
+	errs := make(chan error, len(batches))
+	for i := range batches {
+		batches[i] = make([]Tasker, 1e2)
+		for j := range batches[i] {
+			batches[i][j] = &counter{}
+		}
+		go func(b []Tasker) { errs <- p.Submit(b) }(batches[i])
+	}
+	for range batches {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, b := range batches {
+		for _, e := range b {
+			if n := e.(*counter).n; n != 1 {
+				t.Fatalf("task executed %d times", n)
+			}
+		}
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	initTests()
+	p := NewPool(0)
+	defer p.Close()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Submit(testCases)
+	}
+}
+
+// poolCanceler cancels its Pool when executed.
+type poolCanceler struct {
+	p *Pool
+}
+
+func (c poolCanceler) Execute() { c.p.Cancel() }
+
+func TestPool_cancel(t *testing.T) {
+	p := NewPool(1)
+	defer p.Close()
+	tasks := []Tasker{poolCanceler{p}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	if err := p.Submit(tasks); err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	var executed int
+	for i, e := range tasks[1:] {
+		c := e.(*cancelable)
+		if c.executed+c.canceled != 1 {
+			t.Fatalf("task %d executed %d times and canceled %d times", i, c.executed, c.canceled)
+		}
+		executed += int(c.executed)
+	}
+	if executed == len(tasks)-1 {
+		t.Fatal("all tasks executed after cancel")
+	}
+	// Pool is still usable.
+	initTests()
+	if err := p.Submit(testCases); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPool_pause(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+	p.Pause()
+	// Pausing twice is harmless.
+	p.Pause()
+	c := &counter{}
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = c
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Submit(tasks) }()
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&c.n); n != 0 {
+		t.Fatalf("%d tasks executed while paused", n)
+	}
+	p.Resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if c.n != int32(len(tasks)) {
+		t.Fatalf("expected %d executions, got %d", len(tasks), c.n)
+	}
+	// A paused Pool can be closed.
+	p.Pause()
+	p.Close()
+}
+
+func TestPool_pauseIdle(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+	// Let workers wait for Taskers before pausing.
+	time.Sleep(10 * time.Millisecond)
+	p.Pause()
+	c := &counter{}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Submit([]Tasker{c, c, c}) }()
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&c.n); n != 0 {
+		t.Fatalf("%d tasks executed while paused", n)
+	}
+	p.Resume()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPool_closePaused(t *testing.T) {
+	p := NewPool(1)
+	p.Pause()
+	c := &counter{}
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = c
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Submit(tasks) }()
+	time.Sleep(10 * time.Millisecond)
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close of a paused Pool did not return")
+	}
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&c.n); n != int32(len(tasks)) {
+		t.Fatalf("expected %d executions, got %d", len(tasks), n)
+	}
+}
+
+func TestPool_active(t *testing.T) {
+	p := NewPool(3)
+	defer p.Close()
+	if n := p.Active(); n != 0 {
+		t.Fatalf("expected no active tasks, got %d", n)
+	}
+	s := stuck{make(chan struct{})}
+	errc := make(chan error, 1)
+	go func() { errc <- p.Submit([]Tasker{s, s}) }()
+	for p.Active() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	close(s.release)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Active(); n != 0 {
+		t.Fatalf("expected no active tasks, got %d", n)
+	}
+}
+
+func TestPool_submitChan(t *testing.T) {
+	p := NewPool(3)
+	defer p.Close()
+	c := &counter{}
+	for round := 1; round <= 3; round++ {
+		for i := 0; i < 1e2; i++ {
+			p.SubmitChan() <- c
+		}
+		if err := p.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if n := atomic.LoadInt32(&c.n); n != int32(round*1e2) {
+			t.Fatalf("expected %d executions, got %d", round*1e2, n)
+		}
+	}
+	p.SubmitChan() <- panicker{}
+	p.SubmitChan() <- c
+	var pe *PanicError
+	err := p.Wait()
+	if !errors.As(err, &pe) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 0 {
+		t.Fatal("expected a *TaskError for task 0, got", err)
+	}
+	// Errors are reported once.
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	if err := p.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPool_cancelTask(t *testing.T) {
+	p := NewPool(2)
+	defer p.Close()
+	b0, b1 := newBlocker(), newBlocker()
+	errc := make(chan error, 1)
+	go func() { errc <- p.SubmitCtx([]CtxTasker{b0, b1}) }()
+	<-b0.started
+	<-b1.started
+	if !p.CancelTask(0) {
+		t.Fatal("expected task 0 to be signaled")
+	}
+	<-b0.stopped
+	select {
+	case <-b1.stopped:
+		t.Fatal("task 1 should still be running")
+	default:
+	}
+	if p.CancelTask(5) {
+		t.Fatal("no task should be running at index 5")
+	}
+	p.CancelTask(1)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	<-b1.stopped
+}
+
+func TestPool_submitAsync(t *testing.T) {
+	p := NewPool(3)
+	results := make(chan PoolResult, 10)
+	for i := 0; i < 10; i++ {
+		var task Tasker = &square{n: i}
+		if i == 5 {
+			task = panicker{}
+		}
+		if err := p.SubmitAsync(task, fmt.Sprint("item ", i), results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var panicked int
+	for i := 0; i < 10; i++ {
+		r := <-results
+		var n int
+		fmt.Sscanf(r.UserData.(string), "item %d", &n)
+		if n == 5 {
+			var pe *PanicError
+			if !errors.As(r.Err, &pe) {
+				t.Fatal("expected a *PanicError, got", r.Err)
+			}
+			panicked++
+			continue
+		}
+		if r.Err != nil {
+			t.Fatal(r.Err)
+		}
+		if s := r.Task.(*square); s.n != n || s.result != n*n {
+			t.Fatalf("result %+v delivered with userdata %q", s, r.UserData)
+		}
+	}
+	if panicked != 1 {
+		t.Fatalf("expected 1 panic, got %d", panicked)
+	}
+	p.Close()
+	if err := p.SubmitAsync(&dummy{}, nil, results); err != ErrPoolClosed {
+		t.Fatal("expected ErrPoolClosed, got", err)
+	}
+}
+
+func TestPool_shutdown(t *testing.T) {
+	p := NewPool(2)
+	results := make(chan PoolResult, 10)
+	var tasks []*dummy
+	for i := 0; i < 10; i++ {
+		d := &dummy{}
+		tasks = append(tasks, d)
+		if err := p.SubmitAsync(d, i, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Shutdown()
+	for i, d := range tasks {
+		if !d.done {
+			t.Fatal("queued task not executed:", i)
+		}
+	}
+	if err := p.SubmitAsync(&dummy{}, nil, results); err != ErrPoolClosed {
+		t.Fatal("expected ErrPoolClosed, got", err)
+	}
+}
+
+func TestPool_shutdownNow(t *testing.T) {
+	p := NewPool(1)
+	s := stuck{make(chan struct{})}
+	tasks := []Tasker{s}
+	for i := 0; i < 5; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	submitted := make(chan error)
+	go func() { submitted <- p.Submit(tasks) }()
+	for p.Active() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	unstarted := make(chan []Tasker)
+	go func() { unstarted <- p.ShutdownNow() }()
+	for !p.halted() {
+		time.Sleep(time.Millisecond)
+	}
+	close(s.release)
+	if err := <-submitted; err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	left := <-unstarted
+	if len(left) != 5 {
+		t.Fatalf("expected 5 unstarted tasks, got %d", len(left))
+	}
+	for i, e := range left {
+		c := e.(*cancelable)
+		if c.executed != 0 || c.canceled != 1 {
+			t.Fatalf("task %d executed %d times and canceled %d times", i, c.executed, c.canceled)
+		}
+	}
+	if err := p.Submit(tasks); err != ErrPoolClosed {
+		t.Fatal("expected ErrPoolClosed, got", err)
+	}
+	if left := p.ShutdownNow(); left != nil {
+		t.Fatal("expected nil from a closed pool, got", left)
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoQuorum says that fewer Taskers than the
+// quorum succeeded.
+var ErrNoQuorum = errors.New("quorum not reached")
+
+// ErrInvalidQuorum says that a quorum is less than 1
+// or greater than the number of Taskers.
+var ErrInvalidQuorum = errors.New("quorum must be between 1 and the number of tasks")
+
+// Any executes jobs in parallel and reports whether pred returns
+// true for at least one of them. As soon as a true is found
+// remaining Taskers are not started.
+func Any(jobs []Tasker, pred func(Tasker) bool) bool {
+	return shortCircuit(jobs, pred, true)
+}
+
+// All executes jobs in parallel and reports whether pred returns
+// true for all of them. As soon as a false is found
+// remaining Taskers are not started.
+func All(jobs []Tasker, pred func(Tasker) bool) bool {
+	return !shortCircuit(jobs, pred, false)
+}
+
+// shortCircuit runs jobs until pred returns want for one of them.
+// It reports whether that happened.
+func shortCircuit(jobs []Tasker, pred func(Tasker) bool, want bool) bool {
+	sc := newShortCircuiter()
+	s := &sc
+	wrapped := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		wrapped[i] = &predTask{Tasker: j, pred: pred, want: want, s: s}
+	}
+	s.run(wrapped)
+	return s.tripped()
+}
+
+// shortCircuiter stops a run when it's
+// tripped by one of its Taskers.
+type shortCircuiter struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	found  int32
+}
+
+func newShortCircuiter() shortCircuiter {
+	return shortCircuiterContext(context.Background())
+}
+
+// shortCircuiterContext is like newShortCircuiter
+// but s is stopped once ctx is done too.
+func shortCircuiterContext(ctx context.Context) shortCircuiter {
+	ctx, cancel := context.WithCancel(ctx)
+	return shortCircuiter{ctx: ctx, cancel: cancel}
+}
+
+func (s *shortCircuiter) trip() {
+	atomic.StoreInt32(&s.found, 1)
+	s.cancel()
+}
+
+func (s *shortCircuiter) tripped() bool {
+	return atomic.LoadInt32(&s.found) == 1
+}
+
+// stopped reports whether Taskers should not be started anymore.
+func (s *shortCircuiter) stopped() bool {
+	return s.ctx.Err() != nil
+}
+
+// errSkipped is returned by wrappers that found their run stopped
+// before starting a Tasker, which is then not executed.
+var errSkipped = errors.New("task skipped")
+
+// skip handles t as a Tasker not started because the
+// run stopped, calling OnCancel and Cleanup on it.
+func (s *shortCircuiter) skip(t Tasker) error {
+	cancelRemaining([]indexedTask{{Tasker: t}})
+	return errSkipped
+}
+
+// run executes wrapped, that must trip s, releasing s resources.
+// Tripping is not considered an error.
+func (s *shortCircuiter) run(wrapped []Tasker) error {
+	return s.runWith(wrapped, Options{})
+}
+
+// runWith is like run but uses opts.
+func (s *shortCircuiter) runWith(wrapped []Tasker, opts Options) error {
+	defer s.cancel()
+	err := run(s.ctx, wrapped, opts)
+	if s.tripped() {
+		return nil
+	}
+	return err
+}
+
+// predTask evaluates pred after executing the wrapped Tasker.
+type predTask struct {
+	Tasker
+	pred func(Tasker) bool
+	want bool
+	s    *shortCircuiter
+}
+
+func (p *predTask) Execute() {
+	p.executeErr()
+}
+
+func (p *predTask) executeErr() error {
+	// Taskers already in the queue when the answer
+	// was found are skipped.
+	if p.s.stopped() {
+		return p.s.skip(p.Tasker)
+	}
+	p.Tasker.Execute()
+	if p.pred(p.Tasker) == p.want {
+		p.s.trip()
+	}
+	return nil
+}
+
+// OnCancel notifies the wrapped Tasker, if it's a Canceler.
+func (p *predTask) OnCancel() {
+	if c, ok := p.Tasker.(Canceler); ok {
+		c.OnCancel()
+	}
+}
+
+// RunUntil applies f to inputs in parallel using workers workers,
+// zero or less meaning the package default, until one call returns
+// true: remaining inputs are not processed and the context of running
+// calls is canceled. It returns the output of the first call returning
+// true, if any, otherwise the zero value and false, e.g. when ctx is
+// done first. A panic of f is propagated as a *PanicError.
+func RunUntil[In, Out any](ctx context.Context, inputs []In, f func(context.Context, In) (Out, bool), workers int) (Out, bool) {
+	u := &until[Out]{shortCircuiter: shortCircuiterContext(ctx)}
+	tasks := make([]Tasker, len(inputs))
+	for i, in := range inputs {
+		tasks[i] = &untilTask[In, Out]{f: f, in: in, u: u}
+	}
+	err := u.runWith(tasks, workersOptions(workers))
+	var pe *PanicError
+	if !u.tripped() && errors.As(err, &pe) {
+		panic(pe)
+	}
+	return u.out, u.tripped()
+}
+
+// until keeps the first output found by RunUntil.
+type until[Out any] struct {
+	shortCircuiter
+	once sync.Once
+	out  Out
+}
+
+func (u *until[Out]) found(out Out) {
+	u.once.Do(func() {
+		u.out = out
+		u.trip()
+	})
+}
+
+// untilTask is executed by workers with executeCtx.
+type untilTask[In, Out any] struct {
+	f  func(context.Context, In) (Out, bool)
+	in In
+	u  *until[Out]
+}
+
+func (t *untilTask[In, Out]) Execute() {
+	t.executeCtx(context.Background())
+}
+
+func (t *untilTask[In, Out]) executeCtx(ctx context.Context) error {
+	if out, ok := t.f(ctx, t.in); ok {
+		t.u.found(out)
+	}
+	return nil
+}
+
+// RunUntilSum executes jobs in parallel accumulating value of every
+// completed Tasker, remaining Taskers are not started once the
+// sum reaches target. It returns how many Taskers were executed and the
+// final sum, which accounts also for Taskers already running when
+// target was reached.
+func RunUntilSum(jobs []Tasker, value func(Tasker) float64, target float64) (ran int, sum float64, err error) {
+	acc := &accumulator{
+		shortCircuiter: newShortCircuiter(),
+		value:          value,
+		target:         target,
+	}
+	wrapped := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		wrapped[i] = &sumTask{Tasker: j, acc: acc}
+	}
+	err = acc.run(wrapped)
+	return acc.ran, acc.sum, err
+}
+
+// accumulator sums values of completed Taskers,
+// tripping when target is reached.
+type accumulator struct {
+	shortCircuiter
+	value  func(Tasker) float64
+	target float64
+	mu     sync.Mutex
+	ran    int
+	sum    float64
+}
+
+func (a *accumulator) add(t Tasker) {
+	v := a.value(t)
+	a.mu.Lock()
+	a.ran++
+	a.sum += v
+	reached := a.sum >= a.target
+	a.mu.Unlock()
+	if reached {
+		a.trip()
+	}
+}
+
+// sumTask adds the value of the wrapped Tasker to acc.
+type sumTask struct {
+	Tasker
+	acc *accumulator
+}
+
+func (s *sumTask) Execute() {
+	s.executeErr()
+}
+
+func (s *sumTask) executeErr() error {
+	if s.acc.stopped() {
+		return s.acc.skip(s.Tasker)
+	}
+	s.Tasker.Execute()
+	s.acc.add(s.Tasker)
+	return nil
+}
+
+// OnCancel notifies the wrapped Tasker, if it's a Canceler.
+func (s *sumTask) OnCancel() {
+	if c, ok := s.Tasker.(Canceler); ok {
+		c.OnCancel()
+	}
+}
+
+// RunQuorum executes jobs in parallel until quorum of them succeeded,
+// e.g. the majority of replicas acknowledging a write. Remaining
+// Taskers are then canceled: the ones not started are not executed
+// and, if they implement Canceler, are notified. It returns indices
+// in jobs of the Taskers that formed the quorum, in ascending order.
+// Errors of other Taskers are ignored once the quorum is reached,
+// otherwise they are returned joined with ErrNoQuorum.
+func RunQuorum(jobs []ErrTasker, quorum int) ([]int, error) {
+	if quorum < 1 || quorum > len(jobs) {
+		return nil, ErrInvalidQuorum
+	}
+	q := &quorumer{shortCircuiter: newShortCircuiter(), quorum: quorum}
+	wrapped := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		wrapped[i] = &quorumTask{j: j, i: i, q: q}
+	}
+	err := q.run(wrapped)
+	sort.Ints(q.formed)
+	if err != nil {
+		return q.formed, errors.Join(ErrNoQuorum, err)
+	}
+	if !q.tripped() {
+		return q.formed, ErrNoQuorum
+	}
+	return q.formed, nil
+}
+
+// quorumer collects Taskers that succeeded,
+// tripping when they are quorum.
+type quorumer struct {
+	shortCircuiter
+	quorum int
+	mu     sync.Mutex
+	formed []int
+}
+
+func (q *quorumer) succeeded(i int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	// Taskers running when the quorum was
+	// reached are not part of it.
+	if len(q.formed) == q.quorum {
+		return
+	}
+	q.formed = append(q.formed, i)
+	if len(q.formed) == q.quorum {
+		q.trip()
+	}
+}
+
+// quorumTask reports to q whether the i-th ErrTasker succeeded.
+type quorumTask struct {
+	j ErrTasker
+	i int
+	q *quorumer
+}
+
+func (t *quorumTask) Execute() {
+	t.executeErr()
+}
+
+func (t *quorumTask) executeErr() error {
+	if t.q.stopped() {
+		t.OnCancel()
+		return nil
+	}
+	err := (&errTask{ErrTasker: t.j}).executeErr()
+	if err == nil {
+		t.q.succeeded(t.i)
+	}
+	return err
+}
+
+// OnCancel notifies the ErrTasker, if it's a Canceler.
+func (t *quorumTask) OnCancel() {
+	if c, ok := t.j.(Canceler); ok {
+		c.OnCancel()
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func isDone(t Tasker) bool { return t.(*dummy).done }
+
+func TestAny(t *testing.T) {
+	tasks := make([]Tasker, 1e3)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	if !Any(tasks, isDone) {
+		t.Fatal("expected Any to be true")
+	}
+	var executed int
+	for _, e := range tasks {
+		if e.(*dummy).done {
+			executed++
+		}
+	}
+	if executed == len(tasks) {
+		t.Fatal("Any did not short-circuit")
+	}
+	if Any(tasks[:0], isDone) {
+		t.Fatal("expected Any on no tasks to be false")
+	}
+}
+
+func TestAll(t *testing.T) {
+	tasks := make([]Tasker, 1e1)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	if !All(tasks, isDone) {
+		t.Fatal("expected All to be true")
+	}
+	tasks = make([]Tasker, 1e3)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	if All(tasks, func(Tasker) bool { return false }) {
+		t.Fatal("expected All to be false")
+	}
+	var executed int
+	for _, e := range tasks {
+		if e.(*dummy).done {
+			executed++
+		}
+	}
+	if executed == len(tasks) {
+		t.Fatal("All did not short-circuit")
+	}
+}
+
+// cancelCloser counts executions, cancellations and cleanups.
+type cancelCloser struct {
+	cancelable
+	cleaned int32
+}
+
+func (c *cancelCloser) Cleanup() { atomic.AddInt32(&c.cleaned, 1) }
+
+func TestAny_cancel(t *testing.T) {
+	withWorkers(t, 2)
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &cancelCloser{}
+	}
+	if !Any(tasks, func(Tasker) bool { return true }) {
+		t.Fatal("expected Any to be true")
+	}
+	for i, e := range tasks {
+		c := e.(*cancelCloser)
+		n, m := atomic.LoadInt32(&c.executed), atomic.LoadInt32(&c.canceled)
+		if n+m != 1 || c.cleaned != m {
+			t.Fatalf("task %d executed %d times, canceled %d times and cleaned up %d times", i, n, m, c.cleaned)
+		}
+	}
+}
+
+func TestPredTask_skipped(t *testing.T) {
+	s := newShortCircuiter()
+	s.trip()
+	c := &cancelCloser{}
+	r := runTasks(context.Background(), []Tasker{&predTask{Tasker: c, s: &s}}, Options{})
+	if r.executed[0] || r.err != nil || r.errs[0] != nil {
+		t.Fatalf("expected a skipped task, got executed %v and %v", r.executed[0], r.error())
+	}
+	if c.executed != 0 || c.canceled != 1 || c.cleaned != 1 {
+		t.Fatalf("unexpected task %+v", c)
+	}
+}
+
+func TestRunUntilSum(t *testing.T) {
+	tasks := make([]Tasker, 1e3)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	ran, sum, err := RunUntilSum(tasks, func(Tasker) float64 { return 1 }, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum < 10 {
+		t.Fatalf("expected sum to reach 10, got %f", sum)
+	}
+	if float64(ran) != sum {
+		t.Fatalf("expected %d executed tasks to sum to %f", ran, sum)
+	}
+	var executed int
+	for _, e := range tasks {
+		if e.(*dummy).done {
+			executed++
+		}
+	}
+	if executed != ran {
+		t.Fatalf("reported %d executed tasks, found %d", ran, executed)
+	}
+	if executed == len(tasks) {
+		t.Fatal("dispatch did not stop once target was reached")
+	}
+}
+
+func TestRunUntilSum_cancel(t *testing.T) {
+	withWorkers(t, 2)
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &cancelCloser{}
+	}
+	ran, _, err := RunUntilSum(tasks, func(Tasker) float64 { return 1 }, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var executed int
+	for i, e := range tasks {
+		c := e.(*cancelCloser)
+		n, m := atomic.LoadInt32(&c.executed), atomic.LoadInt32(&c.canceled)
+		if n+m != 1 || c.cleaned != m {
+			t.Fatalf("task %d executed %d times, canceled %d times and cleaned up %d times", i, n, m, c.cleaned)
+		}
+		executed += int(n)
+	}
+	if executed != ran {
+		t.Fatalf("reported %d executed tasks, found %d", ran, executed)
+	}
+}
+
+// voter is an ErrTasker acknowledging unless nack is set.
+type voter struct {
+	nack     bool
+	executed int
+	canceled int
+}
+
+func (v *voter) Execute() error {
+	v.executed++
+	if v.nack {
+		return errOdd
+	}
+	return nil
+}
+
+func (v *voter) OnCancel() { v.canceled++ }
+
+func TestRunQuorum(t *testing.T) {
+	withWorkers(t, 1)
+	voters := []*voter{{}, {nack: true}, {}, {}, {}}
+	jobs := make([]ErrTasker, len(voters))
+	for i, v := range voters {
+		jobs[i] = v
+	}
+	formed, err := RunQuorum(jobs, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(formed, []int{0, 2}) {
+		t.Fatalf("expected quorum of tasks 0 and 2, got %v", formed)
+	}
+	for i, v := range voters[3:] {
+		if v.executed != 0 || v.canceled != 1 {
+			t.Fatalf("task %d executed %d times, canceled %d", i+3, v.executed, v.canceled)
+		}
+	}
+	formed, err = RunQuorum(jobs[:3], 3)
+	if !errors.Is(err, ErrNoQuorum) || !errors.Is(err, errOdd) {
+		t.Fatal("expected ErrNoQuorum and task error, got", err)
+	}
+	if len(formed) != 2 {
+		t.Fatalf("expected 2 successes, got %v", formed)
+	}
+	if _, err := RunQuorum(jobs, 6); err != ErrInvalidQuorum {
+		t.Fatal("expected ErrInvalidQuorum, got", err)
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	inputs := make([]int, 1e3)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	var calls int32
+	find := func(ctx context.Context, i int) (string, bool) {
+		atomic.AddInt32(&calls, 1)
+		return strconv.Itoa(i), i == 100
+	}
+	for _, w := range testWorkers {
+		calls = 0
+		out, ok := RunUntil(context.Background(), inputs, find, w)
+		if !ok || out != "100" {
+			t.Fatalf("workers=%d: expected to find 100, got %q %v", w, out, ok)
+		}
+		if n := atomic.LoadInt32(&calls); n == int32(len(inputs)) {
+			t.Fatalf("workers=%d: all inputs processed", w)
+		}
+	}
+	if _, ok := RunUntil(context.Background(), inputs[:100], find, 0); ok {
+		t.Fatal("found a missing input")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := RunUntil(ctx, inputs, find, 0); ok {
+		t.Fatal("found with ctx done")
+	}
+	// Running calls are canceled once found.
+	wait := func(ctx context.Context, i int) (int, bool) {
+		if i == 0 {
+			<-ctx.Done()
+			return 0, false
+		}
+		return i, true
+	}
+	if out, ok := RunUntil(context.Background(), []int{0, 1}, wait, 2); !ok || out != 1 {
+		t.Fatalf("expected to find 1, got %d %v", out, ok)
+	}
+	defer func() {
+		if _, ok := recover().(*PanicError); !ok {
+			t.Fatal("expected a *PanicError panic")
+		}
+	}()
+	RunUntil(context.Background(), inputs, func(context.Context, int) (int, bool) { panic("boom") }, 0)
+}
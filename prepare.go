@@ -0,0 +1,54 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+)
+
+// ErrAlreadyStarted says that a Prepared run has already been started.
+var ErrAlreadyStarted = errors.New("run already started")
+
+// Prepared is a run validated by Prepare, ready to be started.
+type Prepared struct {
+	jobs    []Tasker
+	opts    Options
+	started atomic.Bool
+}
+
+// Prepare validates jobs and opts, returning a run to be started
+// later with Start, so that setup and validation errors are separated
+// from the execution. A nil Tasker yields a *TaskError wrapping
+// ErrNilTask. Struct Taskers that are not pointers, whose Execute
+// can not store results in the Tasker, are reported to opts.Logger.
+// The number of workers is fixed by Prepare, later calls to
+// SetWorkers do not affect the run.
+func Prepare(jobs []Tasker, opts Options) (*Prepared, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	for i, j := range jobs {
+		if j == nil {
+			return nil, &TaskError{Index: i, Err: ErrNilTask}
+		}
+		if reflect.TypeOf(j).Kind() == reflect.Struct {
+			logEvent(opts.Logger, "parallel: value receiver task", "task", i)
+		}
+	}
+	opts.Workers = opts.workers()
+	return &Prepared{jobs: jobs, opts: opts}, nil
+}
+
+// Start executes the prepared run as RunWith does.
+// A run can be started once, later calls return ErrAlreadyStarted.
+func (p *Prepared) Start() error {
+	if !p.started.CompareAndSwap(false, true) {
+		return ErrAlreadyStarted
+	}
+	return run(context.Background(), p.jobs, p.opts)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPrepare(t *testing.T) {
+	withWorkers(t, 2)
+	initTests()
+	p, err := Prepare(testCases, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range testCases {
+		if e.(*dummy).done {
+			t.Fatal("task executed before Start")
+		}
+	}
+	// The number of workers is fixed by Prepare.
+	withWorkers(t, 5)
+	if p.opts.Workers != 2 {
+		t.Fatalf("expected 2 workers, got %d", p.opts.Workers)
+	}
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range testCases {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed")
+		}
+	}
+	if err := p.Start(); err != ErrAlreadyStarted {
+		t.Fatal("expected ErrAlreadyStarted, got", err)
+	}
+}
+
+func TestPrepare_invalid(t *testing.T) {
+	if _, err := Prepare(nil, Options{Workers: -1}); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+	_, err := Prepare([]Tasker{&dummy{}, nil}, Options{})
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 || !errors.Is(err, ErrNilTask) {
+		t.Fatal("expected a *TaskError wrapping ErrNilTask, got", err)
+	}
+	l := &eventLogger{events: make(map[string]int)}
+	if _, err := Prepare([]Tasker{&dummy{}, dummyNop{}}, Options{Logger: l}); err != nil {
+		t.Fatal(err)
+	}
+	if l.events["parallel: value receiver task"] != 1 {
+		t.Fatal("expected a value receiver event")
+	}
+}
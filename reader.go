@@ -0,0 +1,61 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+)
+
+// RunReader executes a Tasker for every line read from r, built
+// calling parse on the line without its newline, using workers workers,
+// zero or less meaning the package default.
+// Lines are read only as fast as workers take Taskers so memory stays
+// bounded whatever the size of r. Signals abort the run as in Run.
+// Errors of Taskers are joined with the error of the scan, if any.
+func RunReader(r io.Reader, parse func([]byte) Tasker, workers int) error {
+	return RunReaderContext(context.Background(), r, parse, workers)
+}
+
+// RunReaderContext is like RunReader but stops reading
+// lines as soon as ctx is done, as RunContext does.
+func RunReaderContext(ctx context.Context, r io.Reader, parse func([]byte) Tasker, workers int) error {
+	return runReader(ctx, r, parse, workersOptions(workers))
+}
+
+// RunReaderWith is like RunReader but configures
+// workers with opts as RunSeq does.
+func RunReaderWith(r io.Reader, parse func([]byte) Tasker, opts Options) error {
+	return runReader(context.Background(), r, parse, opts)
+}
+
+func runReader(ctx context.Context, r io.Reader, parse func([]byte) Tasker, opts Options) error {
+	return runFed(ctx, opts, func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+		return scanQueue(ctx, r, parse, jobsQueue, signals, opts.Metrics)
+	})
+}
+
+// scanQueue sends to jobsQueue a Tasker for every line of r as
+// seqQueue does, closing it at the end. It returns the error that
+// stopped the scan, if any.
+func scanQueue(ctx context.Context, r io.Reader, parse func([]byte) Tasker, jobsQueue chan<- indexedTask, signals []os.Signal, m Metrics) error {
+	s := bufio.NewScanner(r)
+	lines := func(yield func(Tasker) bool) {
+		for s.Scan() {
+			// The Tasker can retain the line while
+			// the scanner reuses its buffer.
+			line := append([]byte(nil), s.Bytes()...)
+			if !yield(parse(line)) {
+				return
+			}
+		}
+	}
+	if err := seqQueue(ctx, lines, jobsQueue, signals, m); err != nil {
+		return err
+	}
+	return s.Err()
+}
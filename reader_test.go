@@ -0,0 +1,99 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// lineSum adds the number on its line to sum.
+type lineSum struct {
+	line []byte
+	sum  *int64
+}
+
+func (l lineSum) Execute() error {
+	n, err := strconv.Atoi(string(l.line))
+	if err != nil {
+		return err
+	}
+	atomic.AddInt64(l.sum, int64(n))
+	return nil
+}
+
+func TestRunReader(t *testing.T) {
+	var b strings.Builder
+	var expected int64
+	for i := 0; i < 1e3; i++ {
+		b.WriteString(strconv.Itoa(i) + "\n")
+		expected += int64(i)
+	}
+	var sum int64
+	parse := func(line []byte) Tasker {
+		return &errTask{ErrTasker: lineSum{line, &sum}}
+	}
+	if err := RunReader(strings.NewReader(b.String()), parse, 3); err != nil {
+		t.Fatal(err)
+	}
+	if sum != expected {
+		t.Fatalf("expected sum %d, got %d", expected, sum)
+	}
+	err := RunReader(strings.NewReader("1\nx\n2"), parse, 0)
+	var numErr *strconv.NumError
+	if !errors.As(err, &numErr) {
+		t.Fatal("expected a *strconv.NumError, got", err)
+	}
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected a *TaskError for line 1, got", err)
+	}
+}
+
+// endless is an io.Reader of infinite lines.
+type endless struct{}
+
+func (endless) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = '\n'
+	}
+	return len(p), nil
+}
+
+func TestRunReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var lines int32
+	parse := func([]byte) Tasker {
+		if atomic.AddInt32(&lines, 1) == 1e2 {
+			cancel()
+		}
+		return &dummy{}
+	}
+	err := RunReaderContext(ctx, endless{}, parse, 2)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
+
+func TestRunReaderWith(t *testing.T) {
+	var sum int64
+	parse := func(line []byte) Tasker {
+		return &errTask{ErrTasker: lineSum{line, &sum}}
+	}
+	err := RunReaderWith(strings.NewReader("1\nx\n2"), parse, Options{Workers: 1, FailFast: true})
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected a *TaskError for line 1, got", err)
+	}
+	err = RunReaderWith(strings.NewReader("1"), parse, Options{QueueDepth: -1})
+	if err != ErrInvalidQueueDepth {
+		t.Fatal("expected ErrInvalidQueueDepth, got", err)
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "context"
+
+// RecursiveTasker models an heavy task that can discover more work,
+// e.g. a directory walk, queuing new Taskers by calling spawn.
+type RecursiveTasker interface {
+	Execute(spawn func(Tasker))
+}
+
+// Recursive adapts r to Tasker so that it can be passed to RunRecursive
+// or spawned. Executed outside RunRecursive it runs spawned Taskers
+// serially, before returning.
+func Recursive(r RecursiveTasker) Tasker {
+	return recursiveTask{r}
+}
+
+type recursiveTask struct {
+	r RecursiveTasker
+}
+
+func (t recursiveTask) Execute() {
+	t.r.Execute(func(s Tasker) { s.Execute() })
+}
+
+// RunRecursive executes jobs, and Taskers they spawn, in parallel.
+// It returns once no Tasker is queued or running. Errors are
+// returned as in RunSeq, spawned Taskers have index -1.
+func RunRecursive(jobs []Tasker) error {
+	return RunRecursiveContext(context.Background(), jobs, Options{})
+}
+
+// RunRecursiveContext is like RunRecursive but uses opts as RunSeq
+// does and stops dispatching Taskers as soon as ctx is done.
+func RunRecursiveContext(ctx context.Context, jobs []Tasker, opts Options) error {
+	return newDynQueue(jobs).run(ctx, opts)
+}
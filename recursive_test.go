@@ -0,0 +1,83 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// tree spawns a child for each of its depth levels,
+// counting visited nodes.
+type tree struct {
+	depth int
+	nodes *int32
+}
+
+func (t tree) Execute(spawn func(Tasker)) {
+	atomic.AddInt32(t.nodes, 1)
+	for i := 0; i < t.depth; i++ {
+		spawn(Recursive(tree{i, t.nodes}))
+	}
+}
+
+func TestRunRecursive(t *testing.T) {
+	for _, n := range testWorkers {
+		withWorkers(t, n)
+		var nodes int32
+		if err := RunRecursive([]Tasker{Recursive(tree{10, &nodes})}); err != nil {
+			t.Fatal(err)
+		}
+		// A tree of depth d has 2^d nodes.
+		if nodes != 1<<10 {
+			t.Fatalf("%d workers: expected %d nodes, got %d", n, 1<<10, nodes)
+		}
+	}
+	// Outside RunRecursive spawned Taskers run inline.
+	var nodes int32
+	Recursive(tree{5, &nodes}).Execute()
+	if nodes != 1<<5 {
+		t.Fatalf("expected %d nodes, got %d", 1<<5, nodes)
+	}
+}
+
+// spawnPanicker spawns a panicking Tasker.
+type spawnPanicker struct{}
+
+func (spawnPanicker) Execute(spawn func(Tasker)) { spawn(panicker{}) }
+
+func TestRunRecursive_panic(t *testing.T) {
+	err := RunRecursive([]Tasker{Recursive(spawnPanicker{}), &dummy{}})
+	var p *PanicError
+	if !errors.As(err, &p) {
+		t.Fatal("expected a *PanicError, got", err)
+	}
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != -1 {
+		t.Fatal("expected a *TaskError of a spawned task, got", err)
+	}
+	err = RunRecursive([]Tasker{&dummy{}, panicker{}})
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected a *TaskError for task 1, got", err)
+	}
+}
+
+func TestRunRecursiveContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var nodes int32
+	err := RunRecursiveContext(ctx, []Tasker{Recursive(tree{10, &nodes})}, Options{Workers: 2})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if nodes == 1<<10 {
+		t.Fatal("expected the walk to stop")
+	}
+	if err := RunRecursiveContext(context.Background(), nil, Options{Workers: -1}); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+}
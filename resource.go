@@ -0,0 +1,36 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+// ResourceTasker is a Tasker that may use a shared resource,
+// see Options.MaxConcurrentResource.
+type ResourceTasker interface {
+	Tasker
+	ResourceBound() bool
+}
+
+// semaphore bounds resource bound Taskers of a run.
+type semaphore chan struct{}
+
+// newSemaphore returns a semaphore allowing n
+// holders, or nil if n is zero.
+func newSemaphore(n int) semaphore {
+	if n == 0 {
+		return nil
+	}
+	return make(semaphore, n)
+}
+
+// acquire blocks until t can be executed, the returned
+// func must be called once it's done.
+// On a nil semaphore or if t is not resource bound
+// it does not block.
+func (s semaphore) acquire(t Tasker) (release func()) {
+	if r, ok := t.(ResourceTasker); s == nil || !ok || !r.ResourceBound() {
+		return func() {}
+	}
+	s <- struct{}{}
+	return func() { <-s }
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// resourceUser records the maximum number of
+// resource bound Taskers executing together.
+type resourceUser struct {
+	bound     bool
+	in, worst *int32
+}
+
+func (r resourceUser) ResourceBound() bool { return r.bound }
+
+func (r resourceUser) Execute() {
+	if !r.bound {
+		time.Sleep(time.Millisecond)
+		return
+	}
+	n := atomic.AddInt32(r.in, 1)
+	defer atomic.AddInt32(r.in, -1)
+	for {
+		w := atomic.LoadInt32(r.worst)
+		if n <= w || atomic.CompareAndSwapInt32(r.worst, w, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func TestRunWith_maxConcurrentResource(t *testing.T) {
+	var in, worst int32
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = resourceUser{bound: i%2 == 0, in: &in, worst: &worst}
+	}
+	if err := RunWith(tasks, Options{Workers: 8, MaxConcurrentResource: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if worst > 2 {
+		t.Fatalf("%d resource bound tasks executed together, expected at most 2", worst)
+	}
+	if err := RunWith(tasks, Options{MaxConcurrentResource: -1}); err != ErrInvalidResourceLimit {
+		t.Fatal("expected ErrInvalidResourceLimit, got", err)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"reflect"
+)
+
+// Resulter is implemented by Taskers that produce a value
+// once executed.
+type Resulter interface {
+	Result() interface{}
+}
+
+// RunResults executes jobs like Run and returns executed Taskers
+// that implement Resulter, keyed by the Tasker itself. Taskers
+// not executed because the run was aborted are left out. This way results
+// can be looked up using the same pointer that was submitted.
+//
+// Only Taskers with a pointer dynamic type are included. Value Taskers
+// are skipped: they would be compared by value when used as keys
+// (or panic if not comparable) and, as shown in tests, values computed
+// by Execute on a value receiver are lost anyway.
+func RunResults(jobs []Tasker) (map[Tasker]Resulter, error) {
+	rep := runTasks(context.Background(), jobs, Options{})
+	results := make(map[Tasker]Resulter, len(jobs))
+	for i, j := range jobs {
+		r, ok := j.(Resulter)
+		if !ok || !rep.executed[i] || reflect.ValueOf(j).Kind() != reflect.Ptr {
+			continue
+		}
+		results[j] = r
+	}
+	return results, rep.error()
+}
+
+// RunCollect executes jobs like Run and returns the Result of every
+// executed Resulter in jobs order. Results of Taskers that are not
+// Resulters, or were not executed because the run was aborted, are nil.
+func RunCollect(jobs []Tasker) ([]interface{}, error) {
+	return RunCollectWith(jobs, Options{})
+}
+
+// RunCollectWith is like RunCollect but uses opts for this run only.
+// A Tasker that shared the outcome of another one, see
+// Options.SingleFlight, yields the Result of that one.
+func RunCollectWith(jobs []Tasker, opts Options) ([]interface{}, error) {
+	rep := runTasks(context.Background(), jobs, opts)
+	results := make([]interface{}, len(jobs))
+	for i := range jobs {
+		if !rep.executed[i] {
+			continue
+		}
+		j := jobs[i]
+		if l, ok := rep.leaders[i]; ok {
+			j = jobs[l]
+		}
+		if r, ok := j.(Resulter); ok {
+			results[i] = r.Result()
+		}
+	}
+	return results, rep.error()
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+)
+
+type square struct {
+	n      int
+	result int
+}
+
+func (s *square) Execute() { s.result = s.n * s.n }
+
+func (s *square) Result() interface{} { return s.result }
+
+type valueSquare struct{ n int }
+
+func (s valueSquare) Execute() {}
+
+func (s valueSquare) Result() interface{} { return s.n * s.n }
+
+func TestRunResults(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = Tasker(&square{n: i})
+	}
+	tasks = append(tasks, Tasker(valueSquare{n: 3}), Tasker(&dummy{}))
+	results, err := RunResults(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1e2 {
+		t.Fatalf("expected %d results, got %d", int(1e2), len(results))
+	}
+	for i, task := range tasks[:1e2] {
+		r, ok := results[task]
+		if !ok {
+			t.Fatalf("no result for task %d", i)
+		}
+		if r.Result().(int) != i*i {
+			t.Fatalf("task %d: expected %d, got %v", i, i*i, r.Result())
+		}
+	}
+}
+
+func TestRunResults_interrupt(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	tasks := []Tasker{interrupter{&sigChan}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, Tasker(&square{n: i + 1}))
+	}
+	results, err := RunResults(tasks)
+	if err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	if len(results) == len(tasks)-1 {
+		t.Fatal("all tasks completed")
+	}
+	for task, r := range results {
+		if s := task.(*square); r.Result().(int) != s.n*s.n {
+			t.Fatalf("result of a task not executed: %v", r.Result())
+		}
+	}
+}
+
+func TestRunCollect(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = Tasker(&square{n: i})
+	}
+	tasks = append(tasks, Tasker(&dummy{}), Tasker(valueSquare{n: 3}))
+	results, err := RunCollect(tasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(tasks) {
+		t.Fatalf("expected %d results, got %d", len(tasks), len(results))
+	}
+	for i := range tasks[:1e2] {
+		if results[i].(int) != i*i {
+			t.Fatalf("task %d: expected %d, got %v", i, i*i, results[i])
+		}
+	}
+	if results[1e2] != nil || results[1e2+1].(int) != 9 {
+		t.Fatal("unexpected results", results[1e2:])
+	}
+
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	tasks = append([]Tasker{interrupter{&sigChan}}, tasks[:1e2]...)
+	results, err = RunCollect(tasks)
+	if err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	var missing int
+	for _, r := range results[1:] {
+		if r == nil {
+			missing++
+		}
+	}
+	if missing == 0 {
+		t.Fatal("results of tasks not executed")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "context"
+
+// RunCompleted is like RunWith but also returns, in increasing
+// order, the indices of jobs that have been executed, failed ones
+// included. When a signal or a timeout aborts the run it allows
+// resuming it executing only the missing Taskers.
+func RunCompleted(jobs []Tasker, opts Options) (completed []int, err error) {
+	r := runTasks(context.Background(), jobs, opts)
+	return r.completed(), r.error()
+}
+
+// RunCount is like Run but also returns the number of Taskers
+// executed successfully, equal to len(jobs) on a clean run.
+func RunCount(jobs []Tasker) (completed int, err error) {
+	r := runTasks(context.Background(), jobs, Options{})
+	for i, e := range r.executed {
+		if e && r.errs[i] == nil {
+			completed++
+		}
+	}
+	return completed, r.error()
+}
+
+// completed returns the indices of executed Taskers.
+func (r *report) completed() []int {
+	indices := []int{}
+	for i, e := range r.executed {
+		if e {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+)
+
+func TestRunCompleted(t *testing.T) {
+	initTests()
+	completed, err := RunCompleted(testCases, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(completed) != len(testCases) {
+		t.Fatalf("expected %d completed tasks, got %d", len(testCases), len(completed))
+	}
+	for i, e := range completed {
+		if e != i {
+			t.Fatalf("expected index %d, got %d", i, e)
+		}
+	}
+}
+
+func TestRunCompleted_interrupt(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	tasks := []Tasker{interrupter{&sigChan}}
+	for i := 0; i < 1e2; i++ {
+		tasks = append(tasks, &cancelable{})
+	}
+	completed, err := RunCompleted(tasks, Options{})
+	if err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	done := make(map[int]bool)
+	for _, i := range completed {
+		done[i] = true
+	}
+	if len(done) == len(tasks) {
+		t.Fatal("all tasks completed")
+	}
+	for i, e := range tasks[1:] {
+		c := e.(*cancelable)
+		if done[i+1] != (c.executed == 1) {
+			t.Fatalf("task %d executed %d times, reported completed: %t", i+1, c.executed, done[i+1])
+		}
+	}
+}
+
+func TestRunCount(t *testing.T) {
+	initTests()
+	completed, err := RunCount(testCases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed != len(testCases) {
+		t.Fatalf("expected %d completed tasks, got %d", len(testCases), completed)
+	}
+	completed, err = RunCount(append([]Tasker{panicker{}}, testCases...))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if completed != len(testCases) {
+		t.Fatalf("expected %d completed tasks, got %d", len(testCases), completed)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+)
+
+// ErrInvalidFraction says that a sampling fraction is outside [0, 1].
+var ErrInvalidFraction = errors.New("fraction must be in [0, 1]")
+
+// RunSample executes a random subset of jobs so that roughly
+// fraction*len(jobs) Taskers are run. Each Tasker is selected with
+// probability proportional to its weight, capped to 1: WeightedTaskers
+// use Weight, zero or negative weights are never selected, other
+// Taskers weigh 1. With equal weights every Tasker is selected with
+// probability fraction. Selection uses an RNG seeded with seed so the
+// same seed always picks the same subset.
+// It returns the indices of selected jobs in ascending order.
+func RunSample(jobs []Tasker, fraction float64, seed int64) ([]int, error) {
+	if fraction < 0 || fraction > 1 {
+		return nil, ErrInvalidFraction
+	}
+	weights := make([]float64, len(jobs))
+	var total float64
+	for i, j := range jobs {
+		weights[i] = 1
+		if w, ok := j.(WeightedTasker); ok {
+			weights[i] = math.Max(float64(w.Weight()), 0)
+		}
+		total += weights[i]
+	}
+	rng := rand.New(rand.NewSource(seed))
+	var indices []int
+	var selected []Tasker
+	for i, j := range jobs {
+		p := fraction * float64(len(jobs)) * weights[i] / total
+		if rng.Float64() < p {
+			indices = append(indices, i)
+			selected = append(selected, j)
+		}
+	}
+	return indices, Run(selected)
+}
+
+// RunShuffled executes jobs like Run but dispatches them in a random
+// order, reproducible using the same seed. This mitigates load
+// patterns where expensive Taskers are clustered together in jobs.
+// jobs is not modified, see Options.Shuffle.
+func RunShuffled(jobs []Tasker, seed int64) error {
+	return RunWith(jobs, Options{Shuffle: true, ShuffleSeed: seed})
+}
+
+// shuffled returns a shuffled copy of jobs.
+func shuffled[T any](jobs []T, seed int64) []T {
+	s := make([]T, len(jobs))
+	copy(s, jobs)
+	rng := rand.New(rand.NewSource(seed))
+	for i := len(s) - 1; i > 0; i-- {
+		j := rng.Intn(i + 1)
+		s[i], s[j] = s[j], s[i]
+	}
+	return s
+}
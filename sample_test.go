@@ -0,0 +1,151 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunSample(t *testing.T) {
+	tasks := make([]Tasker, 1e3)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+	}
+	indices, err := RunSample(tasks, 0.2, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(indices); n < 150 || n > 250 {
+		t.Fatalf("expected roughly 200 sampled tasks, got %d", n)
+	}
+	selected := make(map[int]bool)
+	for _, i := range indices {
+		selected[i] = true
+	}
+	for i, e := range tasks {
+		if e.(*dummy).done != selected[i] {
+			t.Fatalf("task %d: done=%v, selected=%v", i, e.(*dummy).done, selected[i])
+		}
+	}
+	again, err := RunSample(tasks, 0.2, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indices, again) {
+		t.Fatal("same seed selected a different subset")
+	}
+}
+
+// heavy is a dummy weighing w.
+type heavy struct {
+	dummy
+	w int
+}
+
+func (h *heavy) Weight() int { return h.w }
+
+func TestRunSample_weighted(t *testing.T) {
+	tasks := make([]Tasker, 1e3)
+	for i := range tasks {
+		// Even tasks weigh 9 times odd ones.
+		tasks[i] = Tasker(&heavy{w: 1 + 8*(1-i%2)})
+	}
+	tasks[1] = &heavy{w: 0}
+	indices, err := RunSample(tasks, 0.2, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var even, odd int
+	for _, i := range indices {
+		if i == 1 {
+			t.Fatal("task of weight 0 selected")
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	// Expected are 180 heavy tasks and 20 light ones.
+	if even < 140 || even > 220 || odd > 40 {
+		t.Fatalf("expected roughly 180 heavy and 20 light tasks, got %d and %d", even, odd)
+	}
+}
+
+func TestRunSample_invalidFraction(t *testing.T) {
+	if _, err := RunSample(nil, 1.5, 0); err != ErrInvalidFraction {
+		t.Fatal("expected ErrInvalidFraction, got", err)
+	}
+}
+
+type counter struct {
+	n int32
+}
+
+func (c *counter) Execute() { atomic.AddInt32(&c.n, 1) }
+
+func TestRunShuffled(t *testing.T) {
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = Tasker(&counter{})
+	}
+	a, b := shuffled(tasks, 7), shuffled(tasks, 7)
+	var moved int
+	for i := range tasks {
+		if a[i] != b[i] {
+			t.Fatal("same seed yielded a different order")
+		}
+		if a[i] != tasks[i] {
+			moved++
+		}
+	}
+	if moved == 0 {
+		t.Fatal("tasks were not shuffled")
+	}
+	if err := RunShuffled(tasks, 7); err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range tasks {
+		if n := e.(*counter).n; n != 1 {
+			t.Fatalf("task %d executed %d times", i, n)
+		}
+	}
+}
+
+// orderLogger appends its index to order.
+type orderLogger struct {
+	i     int
+	order *[]int
+}
+
+func (o orderLogger) Execute() { *o.order = append(*o.order, o.i) }
+
+func TestRunWith_shuffle(t *testing.T) {
+	var order []int
+	var indices []int
+	var tasks []Tasker
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, orderLogger{i, &order})
+		indices = append(indices, i)
+	}
+	tasks[5] = panicker{}
+	err := RunWith(tasks, Options{Workers: 1, Shuffle: true, ShuffleSeed: 3})
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 5 {
+		t.Fatal("expected a *TaskError of task 5, got", err)
+	}
+	var want []int
+	for _, i := range shuffled(indices, 3) {
+		if i != 5 {
+			want = append(want, i)
+		}
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("expected order %v, got %v", want, order)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// ErrNotDispatched says that a Scheduler did not return
+// all Taskers, the missing ones are not executed.
+var ErrNotDispatched = errors.New("tasks not dispatched by the scheduler")
+
+// WeightedTasker is a Tasker that knows its relative cost.
+type WeightedTasker interface {
+	Tasker
+	Weight() int
+}
+
+// PriorityTasker is a Tasker that must be dispatched before
+// Taskers of lower priority, see Options.Prioritized.
+type PriorityTasker interface {
+	Tasker
+	Priority() int
+}
+
+// Scheduler decides the order Taskers are dispatched to workers,
+// see Options.Scheduler. It's an ordering hook: all Taskers of a run
+// are added, then drained with Next before the first one is
+// dispatched, Next returns false once it has no more. Next must
+// return every added Tasker, the Taskers themselves rather than
+// copies or wrappers.
+type Scheduler interface {
+	Add(Tasker)
+	Next() (Tasker, bool)
+}
+
+// schedule returns jobs, along with their indices,
+// in the order they must be dispatched to workers.
+// The error, if any, says that some jobs are not dispatched.
+func (o Options) schedule(jobs []Tasker) ([]indexedTask, error) {
+	tasks := make([]indexedTask, len(jobs))
+	for i, j := range jobs {
+		tasks[i] = indexedTask{i, j}
+	}
+	if o.Dedup {
+		tasks = dedup(tasks)
+	}
+	if o.Deterministic {
+		return tasks, nil
+	}
+	if o.Scheduler != nil {
+		return reorder(o.Scheduler, tasks)
+	}
+	if o.Shuffle {
+		// Sorting after shuffling keeps it as the
+		// order for Taskers of equal weight or priority.
+		tasks = shuffled(tasks, o.ShuffleSeed)
+	}
+	if o.Weighted {
+		// Longest processing time first: heavy Taskers start
+		// early and light ones fill the gaps at the end,
+		// so that no core remains idle sooner.
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return weight(tasks[i].Tasker) > weight(tasks[j].Tasker)
+		})
+	}
+	if o.Prioritized {
+		// Sorting after weights keeps them as the
+		// order for Taskers of equal priority.
+		sort.SliceStable(tasks, func(i, j int) bool {
+			return priority(tasks[i].Tasker) > priority(tasks[j].Tasker)
+		})
+	}
+	return tasks, nil
+}
+
+// weight returns the weight of t, zero if it's not a WeightedTasker.
+func weight(t Tasker) int {
+	if w, ok := t.(WeightedTasker); ok {
+		return w.Weight()
+	}
+	return 0
+}
+
+// priority returns the priority of t, zero if it's not a PriorityTasker.
+func priority(t Tasker) int {
+	if p, ok := t.(PriorityTasker); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// dedup removes pointer Taskers already seen in tasks.
+func dedup(tasks []indexedTask) []indexedTask {
+	seen := make(map[Tasker]bool)
+	unique := tasks[:0]
+	for _, t := range tasks {
+		if t.Tasker != nil && reflect.TypeOf(t.Tasker).Kind() == reflect.Ptr {
+			if seen[t.Tasker] {
+				continue
+			}
+			seen[t.Tasker] = true
+		}
+		unique = append(unique, t)
+	}
+	return unique
+}
+
+// reorder returns tasks in the order s dispatches them, along with
+// an error wrapping ErrNotDispatched if s did not return all of them.
+// Nil Taskers and Taskers of non comparable types, e.g. TaskFunc, can
+// not be told apart once returned: they bypass s and are dispatched
+// last in jobs order.
+func reorder(s Scheduler, tasks []indexedTask) ([]indexedTask, error) {
+	indices := make(map[Tasker][]int)
+	var bypass []indexedTask
+	for _, t := range tasks {
+		if t.Tasker == nil || !reflect.TypeOf(t.Tasker).Comparable() {
+			bypass = append(bypass, t)
+			continue
+		}
+		indices[t.Tasker] = append(indices[t.Tasker], t.index)
+		s.Add(t.Tasker)
+	}
+	ordered := make([]indexedTask, 0, len(tasks))
+	for {
+		t, ok := s.Next()
+		if !ok {
+			break
+		}
+		// Taskers never added are ignored.
+		if i := indices[t]; len(i) > 0 {
+			indices[t] = i[1:]
+			ordered = append(ordered, indexedTask{i[0], t})
+		}
+	}
+	ordered = append(ordered, bypass...)
+	if len(ordered) == len(tasks) {
+		return ordered, nil
+	}
+	var dropped []indexedTask
+	for t, indices := range indices {
+		for _, i := range indices {
+			dropped = append(dropped, indexedTask{i, t})
+		}
+	}
+	sort.Slice(dropped, func(i, j int) bool { return dropped[i].index < dropped[j].index })
+	cancelRemaining(dropped)
+	return ordered, fmt.Errorf("%w: %d tasks", ErrNotDispatched, len(dropped))
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type weighted struct {
+	recorder
+	w int
+}
+
+func (w *weighted) Weight() int { return w.w }
+
+func TestRunWith_weighted(t *testing.T) {
+	var order []int
+	weights := []int{1, 5, 3, 5, 0}
+	tasks := make([]Tasker, len(weights))
+	for i, w := range weights {
+		tasks[i] = &weighted{recorder{i: i, order: &order}, w}
+	}
+	tasks = append(tasks, &recorder{i: len(tasks), order: &order})
+	if err := RunWith(tasks, Options{Workers: 1, Weighted: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{1, 3, 2, 0, 4, 5}
+	for i, v := range expected {
+		if order[i] != v {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+type prioritized struct {
+	weighted
+	p int
+}
+
+func (p *prioritized) Priority() int { return p.p }
+
+func TestRunWith_prioritized(t *testing.T) {
+	var order []int
+	priorities := []int{0, 2, 1, 2, -1}
+	weights := []int{0, 1, 0, 3, 0}
+	tasks := make([]Tasker, len(priorities))
+	for i, p := range priorities {
+		tasks[i] = &prioritized{weighted{recorder{i: i, order: &order}, weights[i]}, p}
+	}
+	tasks = append(tasks, &recorder{i: len(tasks), order: &order})
+	if err := RunWith(tasks, Options{Workers: 1, Prioritized: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{1, 3, 2, 0, 5, 4}
+	for i, v := range expected {
+		if order[i] != v {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+	order = nil
+	if err := RunWith(tasks, Options{Workers: 1, Prioritized: true, Weighted: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected = []int{3, 1, 2, 0, 5, 4}
+	for i, v := range expected {
+		if order[i] != v {
+			t.Fatalf("expected order %v, got %v", expected, order)
+		}
+	}
+}
+
+// primeInterval counts primes in an interval, its weight
+// is an estimate of the cost.
+type primeInterval struct {
+	start, stop int
+}
+
+func (p *primeInterval) Execute() {
+	for i := p.start; i <= p.stop; i++ {
+		isPrime(uint64(i))
+	}
+}
+
+func (p *primeInterval) Weight() int { return p.stop }
+
+func BenchmarkWeighted(b *testing.B) {
+	var tasks []Tasker
+	for i := 0; i < 1e5; i += 1e4 {
+		tasks = append(tasks, &primeInterval{start: i, stop: i + 1e4 - 1})
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		RunWith(tasks, Options{Weighted: true})
+	}
+}
+
+func TestRunWith_deterministic(t *testing.T) {
+	var order []int
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &prioritized{weighted{recorder{i: i, order: &order}, i}, i}
+	}
+	opts := Options{Workers: 4, Weighted: true, Prioritized: true, Deterministic: true}
+	if err := RunWith(tasks, opts); err != nil {
+		t.Fatal(err)
+	}
+	for i, e := range order {
+		if e != i {
+			t.Fatalf("expected task %d at position %d, got %d", i, i, e)
+		}
+	}
+}
+
+func TestRunWith_dedup(t *testing.T) {
+	c := &counter{}
+	tasks := []Tasker{c, c, &counter{}, c}
+	for _, workers := range []int{1, 2} {
+		c.n = 0
+		if err := RunWith(tasks, Options{Workers: workers, Dedup: true}); err != nil {
+			t.Fatal(err)
+		}
+		if c.n != 1 {
+			t.Fatalf("%d workers: expected 1 execution, got %d", workers, c.n)
+		}
+	}
+	if err := RunWith(tasks, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if c.n != 4 {
+		t.Fatalf("expected 4 executions without Dedup, got %d", c.n)
+	}
+}
+
+// lifo is a Scheduler dispatching the last added Tasker first.
+type lifo []Tasker
+
+func (l *lifo) Add(t Tasker) { *l = append(*l, t) }
+
+func (l *lifo) Next() (Tasker, bool) {
+	if len(*l) == 0 {
+		return nil, false
+	}
+	t := (*l)[len(*l)-1]
+	*l = (*l)[:len(*l)-1]
+	return t, true
+}
+
+func TestRunWith_scheduler(t *testing.T) {
+	var order []int
+	tasks := make([]Tasker, 10)
+	for i := range tasks {
+		tasks[i] = &recorder{i: i, order: &order}
+	}
+	// Not comparable, it bypasses the Scheduler.
+	tasks = append(tasks, TaskFunc(func() { order = append(order, 10) }))
+	if err := RunWith(tasks, Options{Workers: 1, Scheduler: &lifo{}}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{9, 8, 7, 6, 5, 4, 3, 2, 1, 0, 10}
+	if !reflect.DeepEqual(order, expected) {
+		t.Fatalf("expected order %v, got %v", expected, order)
+	}
+}
+
+// dropper is a Scheduler that never returns the first Tasker.
+type dropper struct{ lifo }
+
+func (d *dropper) Next() (Tasker, bool) {
+	if len(d.lifo) == 1 {
+		return nil, false
+	}
+	return d.lifo.Next()
+}
+
+func TestRunWith_schedulerDrop(t *testing.T) {
+	dropped := &cancelable{}
+	tasks := []Tasker{dropped, &cancelable{}, &cancelable{}}
+	for _, workers := range []int{1, 2} {
+		err := RunWith(tasks, Options{Workers: workers, Scheduler: &dropper{}})
+		if !errors.Is(err, ErrNotDispatched) {
+			t.Fatalf("%d workers: expected ErrNotDispatched, got %v", workers, err)
+		}
+	}
+	if dropped.executed != 0 || dropped.canceled != 2 {
+		t.Fatalf("dropped task executed %d times, canceled %d", dropped.executed, dropped.canceled)
+	}
+}
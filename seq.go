@@ -0,0 +1,157 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"os"
+	"os/signal"
+)
+
+// RunSeq executes Taskers pulled from seq, without collecting them
+// in a slice first. Taskers are pulled only as fast as workers take
+// them, QueueDepth of them at most wait for a worker, so that lazily
+// generated work keeps memory bounded. Signals stop pulling from seq
+// as in Run. opts configures workers as in RunWith and FailFast stops
+// pulling at the first failure. Options that need to know Taskers in
+// advance or shape the report of a run do not apply: Timeout,
+// StallTimeout, ShutdownGrace, OnProgress, OnComplete, MaxErrors,
+// Weighted, Prioritized, Scheduler, Dedup, Shuffle, Affinity, DryRun,
+// Checkpoint, ResultBufferSize, ErrorMode, Strategy, GOMAXPROCS and
+// SerialOnSingleProc. Neither do retries, that are for ErrTaskers.
+// Errors of Taskers are wrapped in *TaskError, whose Index is the
+// position of the Tasker in seq, and joined.
+func RunSeq(seq iter.Seq[Tasker], opts Options) error {
+	return RunSeqContext(context.Background(), seq, opts)
+}
+
+// RunSeqContext is like RunSeq but stops pulling
+// Taskers as soon as ctx is done, as RunContext does.
+func RunSeqContext(ctx context.Context, seq iter.Seq[Tasker], opts Options) error {
+	return runFed(ctx, opts, func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+		return seqQueue(ctx, seq, jobsQueue, signals, opts.Metrics)
+	})
+}
+
+// runFed executes Taskers that feed sends to jobsQueue, closing it
+// at the end, as RunSeq describes. feed returns an error if a signal
+// or ctx stopped it early.
+func runFed(ctx context.Context, opts Options, feed func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	n := opts.workers()
+	jobsQueue := make(chan indexedTask, opts.queueDepth(n))
+	done := make(chan taskDone, n)
+	seqErr := make(chan error, 1)
+	go func() { seqErr <- feed(ctx, jobsQueue, opts.signals()) }()
+	// done is read until closed, workers never stop early.
+	go parallelizeWorkers(ctx, jobsQueue, nil, done, nil, n, opts)
+	var errs errCollector
+	var failed bool
+	for d := range done {
+		if d.err == nil {
+			continue
+		}
+		task := d.task
+		if t, ok := task.(*dynTask); ok {
+			task = t.Tasker
+		}
+		errs.add(&TaskError{Index: d.index, Task: task, Err: d.err})
+		if opts.FailFast && !failed {
+			logEvent(opts.Logger, "parallel: task failed, failing fast", "task", d.index, "err", d.err)
+			failed = true
+			cancel()
+		}
+	}
+	err := <-seqErr
+	if failed {
+		// The error is the one of the failed Tasker.
+		err = nil
+	}
+	return errs.join(err)
+}
+
+// RunChan executes Taskers received from jobs using workers
+// workers, zero or less meaning the package default, until jobs is
+// closed and drained. jobs is read as RunSeq pulls Taskers: signals
+// stop reading it, Taskers left in jobs are not executed.
+// Errors of Taskers are wrapped in *TaskError, whose Index is the
+// position of the Tasker among received ones, and joined.
+func RunChan(jobs <-chan Tasker, workers int) error {
+	return RunChanContext(context.Background(), jobs, workers)
+}
+
+// RunChanContext is like RunChan but stops reading
+// jobs as soon as ctx is done, as RunContext does.
+func RunChanContext(ctx context.Context, jobs <-chan Tasker, workers int) error {
+	return runFed(ctx, workersOptions(workers), func(ctx context.Context, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+		return chanQueue(ctx, jobs, jobsQueue, signals)
+	})
+}
+
+// chanQueue is like seqQueue but forwards Taskers received from
+// jobs, waiting for them can be interrupted too.
+func chanQueue(ctx context.Context, jobs <-chan Tasker, jobsQueue chan<- indexedTask, signals []os.Signal) error {
+	defer close(jobsQueue)
+	signalChan := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		notifySignal(signalChan, signals...)
+		defer signal.Stop(signalChan)
+	}
+	for i := 0; ; i++ {
+		var t indexedTask
+		select {
+		case j, ok := <-jobs:
+			if !ok {
+				return nil
+			}
+			t = indexedTask{i, j}
+		case <-signalChan:
+			return ErrTasksNotCompleted
+		case <-ctx.Done():
+			return fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+		}
+		select {
+		case jobsQueue <- t:
+		case <-signalChan:
+			return ErrTasksNotCompleted
+		case <-ctx.Done():
+			return fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+		}
+	}
+}
+
+// seqQueue sends to jobsQueue Taskers pulled from seq, in order,
+// closing it at the end. It returns an error if a signal or ctx
+// stopped it before seq was exhausted. m, if not nil, counts queued
+// Taskers.
+func seqQueue(ctx context.Context, seq iter.Seq[Tasker], jobsQueue chan<- indexedTask, signals []os.Signal, m Metrics) error {
+	defer close(jobsQueue)
+	signalChan := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		notifySignal(signalChan, signals...)
+		defer signal.Stop(signalChan)
+	}
+	var i int
+	for t := range seq {
+		select {
+		case jobsQueue <- indexedTask{i, t}:
+			if m != nil {
+				m.Submitted()
+			}
+		case <-signalChan:
+			return ErrTasksNotCompleted
+		case <-ctx.Done():
+			return fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+		}
+		i++
+	}
+	return nil
+}
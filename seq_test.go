@@ -0,0 +1,139 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// counted yields n Taskers made by task, counting pulled ones.
+func counted(n int, pulled *int32, task func(i int) Tasker) func(func(Tasker) bool) {
+	return func(yield func(Tasker) bool) {
+		for i := 0; i < n; i++ {
+			atomic.AddInt32(pulled, 1)
+			if !yield(task(i)) {
+				return
+			}
+		}
+	}
+}
+
+func TestRunSeq(t *testing.T) {
+	var pulled int32
+	c := &counter{}
+	seq := counted(1e3, &pulled, func(int) Tasker { return c })
+	if err := RunSeq(seq, Options{Workers: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if c.n != 1e3 || pulled != 1e3 {
+		t.Fatalf("expected 1000 executions, got %d of %d pulled", c.n, pulled)
+	}
+	// Taskers are pulled as workers take them.
+	var executed int32
+	s := stuck{make(chan struct{})}
+	seq = counted(1e3, &pulled, func(i int) Tasker {
+		if i == 0 {
+			return s
+		}
+		return TaskFunc(func() { atomic.AddInt32(&executed, 1) })
+	})
+	pulled = 0
+	done := make(chan error)
+	go func() { done <- RunSeq(seq, Options{Workers: 1, QueueDepth: 4}) }()
+	for atomic.LoadInt32(&pulled) < 5 {
+		time.Sleep(time.Millisecond)
+	}
+	// The stuck Tasker, 4 queued and one waiting to be queued.
+	if n := atomic.LoadInt32(&pulled); n > 6 {
+		t.Fatalf("expected at most 6 pulled tasks, got %d", n)
+	}
+	close(s.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if executed != 999 {
+		t.Fatalf("expected 999 executions, got %d", executed)
+	}
+}
+
+func TestRunSeq_abort(t *testing.T) {
+	var sigChan chan<- os.Signal
+	notifySignal = func(c chan<- os.Signal, sig ...os.Signal) { sigChan = c }
+	defer func() { notifySignal = signal.Notify }()
+	var pulled int32
+	seq := counted(1e3, &pulled, func(i int) Tasker {
+		if i == 0 {
+			return interrupter{&sigChan}
+		}
+		return &dummy{}
+	})
+	if err := RunSeq(seq, Options{Workers: 2}); err != ErrTasksNotCompleted {
+		t.Fatal("expected ErrTasksNotCompleted, got", err)
+	}
+	if pulled == 1e3 {
+		t.Fatal("seq not stopped by the signal")
+	}
+
+	pulled = 0
+	seq = counted(1e3, &pulled, func(i int) Tasker {
+		if i == 10 {
+			return panicker{}
+		}
+		return &dummy{}
+	})
+	err := RunSeq(seq, Options{Workers: 2, FailFast: true})
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 10 {
+		t.Fatal("expected a *TaskError for task 10, got", err)
+	}
+	if errors.Is(err, context.Canceled) {
+		t.Fatal("unexpected context error", err)
+	}
+	if pulled == 1e3 {
+		t.Fatal("seq not stopped by the failure")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := RunSeqContext(ctx, seq, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if err := RunSeq(seq, Options{Workers: -1}); err != ErrInvalidWorkers {
+		t.Fatal("expected ErrInvalidWorkers, got", err)
+	}
+}
+
+func TestRunChan(t *testing.T) {
+	jobs := make(chan Tasker)
+	c := &counter{}
+	go func() {
+		for i := 0; i < 1e3; i++ {
+			jobs <- c
+		}
+		jobs <- panicker{}
+		close(jobs)
+	}()
+	err := RunChan(jobs, 3)
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1e3 {
+		t.Fatal("expected a *TaskError for task 1000, got", err)
+	}
+	if c.n != 1e3 {
+		t.Fatalf("expected 1000 executions, got %d", c.n)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jobs = make(chan Tasker, 1)
+	jobs <- TaskFunc(cancel)
+	// jobs is never closed, ctx stops reading it.
+	if err := RunChanContext(ctx, jobs, 2); !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
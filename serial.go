@@ -0,0 +1,106 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// runSerial executes tasks one after the other in the calling
+// goroutine, as runTasks does with a single worker but without
+// the overhead of goroutines and channels. Signals, ctx and
+// opts.Timeout are checked between Taskers, cancel is called on
+// timeout so that a running CtxTasker can return early.
+func runSerial(ctx context.Context, cancel context.CancelFunc, tasks []indexedTask, opts Options, r *report) {
+	signalChan := make(chan os.Signal, 1)
+	if signals := opts.signals(); len(signals) > 0 {
+		notifySignal(signalChan, signals...)
+		defer signal.Stop(signalChan)
+	}
+	var timedOut int32
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(opts.Timeout, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	lim := newLimiter(opts.RatePerSecond)
+	gl := newGoLimit(opts.GoroutineLimit, 1)
+	wd := newWatchdog(opts, 1)
+	defer wd.stop()
+	if opts.LockWorkerThreads {
+		// Locks nest, a goroutine already locked stays so.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+	}
+	logEvent(opts.Logger, "parallel: worker started", "worker", 0)
+	// Retries are appended to tasks, after
+	// the total fresh ones.
+	total := len(tasks)
+	for i := 0; i < len(tasks); i++ {
+		t := tasks[i]
+		if i >= total {
+			// Back off, at most until ctx is done.
+			t.Tasker.(*errTask).wait(ctx)
+		}
+		// At most until ctx is done too.
+		waitGate(ctx, opts)
+		select {
+		case sig := <-signalChan:
+			logEvent(opts.Logger, "parallel: signal received", "signal", sig)
+			r.err = ErrTasksNotCompleted
+		case <-ctx.Done():
+			if atomic.LoadInt32(&timedOut) == 1 {
+				logEvent(opts.Logger, "parallel: timeout reached", "timeout", opts.Timeout)
+				r.err = ErrTimeout
+				break
+			}
+			logEvent(opts.Logger, "parallel: context done", "err", ctx.Err())
+			r.err = fmt.Errorf("context done, not all tasks have been completed: %w", ctx.Err())
+		default:
+		}
+		if r.err != nil {
+			cancelRemaining(tasks[i:])
+			break
+		}
+		lim.wait()
+		// Without a queue Taskers are submitted as they start,
+		// retries are still in-flight.
+		if opts.Metrics != nil && i < total {
+			opts.Metrics.Submitted()
+			opts.Metrics.Started()
+		}
+		pre := time.Now()
+		wd.started(0, t.index)
+		err := opts.CircuitBreaker.execute(ctx, gl.wrap(t), opts.TaskTimeout)
+		queued, err := retry(ctx, t, err, opts, func(t indexedTask) bool {
+			if len(tasks)-max(i+1, total) >= opts.RetryQueue {
+				return false
+			}
+			tasks = append(tasks, t)
+			return true
+		})
+		wd.done(0)
+		if queued {
+			continue
+		}
+		if opts.Metrics != nil {
+			opts.Metrics.Done(err != nil)
+		}
+		if r.record(taskDone{index: t.index, err: err, duration: time.Since(pre), leader: t.index}, opts, total) {
+			cancelRemaining(tasks[i+1:])
+			break
+		}
+	}
+	logEvent(opts.Logger, "parallel: queue closed")
+	logEvent(opts.Logger, "parallel: worker done", "worker", 0, "tasks", r.finished)
+}
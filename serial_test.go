@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// goroutineID returns the id of the calling goroutine.
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	return bytes.Fields(buf)[1]
+}
+
+// goroutineRecorder records the goroutine executing it.
+type goroutineRecorder struct {
+	id []byte
+}
+
+func (g *goroutineRecorder) Execute() { g.id = goroutineID() }
+
+func TestRunWith_serial(t *testing.T) {
+	g := &goroutineRecorder{}
+	if err := RunWith([]Tasker{g}, Options{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(g.id, goroutineID()) {
+		t.Fatalf("task executed on goroutine %s, expected %s", g.id, goroutineID())
+	}
+	// Outcomes match the parallel path.
+	run := func(workers int) []bool {
+		tasks := []Tasker{&dummy{}, panicker{}, &dummy{}, panicker{}, nil}
+		var all *Errors
+		if !errors.As(RunWith(tasks, Options{Workers: workers, ErrorMode: ErrCollectAll}), &all) {
+			t.Fatal("expected *Errors")
+		}
+		failed := make([]bool, len(all.Tasks))
+		for i, e := range all.Tasks {
+			failed[i] = e != nil
+		}
+		return failed
+	}
+	if serial, parallel := run(1), run(2); !reflect.DeepEqual(serial, parallel) {
+		t.Fatalf("serial failures %v, parallel %v", serial, parallel)
+	}
+}
+
+func TestRunWith_serialTimeout(t *testing.T) {
+	b := newBlocker()
+	err := RunWith([]Tasker{ctxTask{b}, &dummy{}}, Options{Workers: 1, Timeout: 10 * time.Millisecond})
+	if err != ErrTimeout {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	select {
+	case <-b.stopped:
+	default:
+		t.Fatal("task did not receive the timeout")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = run(ctx, []Tasker{&dummy{}}, Options{Workers: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+}
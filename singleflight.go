@@ -0,0 +1,78 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import "sync"
+
+// KeyedTasker is a Tasker whose work is identified by Key,
+// e.g. the URL it fetches, see Options.SingleFlight.
+type KeyedTasker interface {
+	Tasker
+	Key() string
+}
+
+// flights tracks KeyedTaskers being executed by the workers
+// of a run, a nil *flights does not track them.
+type flights struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+// flight is the execution of a KeyedTasker, done is
+// closed once its outcome is set.
+type flight struct {
+	done   chan struct{}
+	index  int
+	queued bool
+	err    error
+}
+
+// newFlights returns flights for a run, nil
+// unless opts.SingleFlight is set.
+func newFlights(opts Options) *flights {
+	if !opts.SingleFlight {
+		return nil
+	}
+	return &flights{calls: make(map[string]*flight)}
+}
+
+// do executes j with f, which reports as retry does whether j has
+// been queued for a later retry. If a KeyedTasker with the same key
+// is being executed j waits for it instead and shares its outcome,
+// leader being the index of the executed one. The outcome of a
+// Tasker queued for a retry is not shared, j is executed then.
+func (fs *flights) do(j indexedTask, f func() (bool, error)) (leader int, queued bool, err error) {
+	k, ok := j.Tasker.(KeyedTasker)
+	if fs == nil || !ok {
+		queued, err = f()
+		return j.index, queued, err
+	}
+	key := k.Key()
+	var c *flight
+	for {
+		fs.mu.Lock()
+		running, ok := fs.calls[key]
+		if !ok {
+			c = &flight{done: make(chan struct{}), index: j.index}
+			fs.calls[key] = c
+		}
+		fs.mu.Unlock()
+		if !ok {
+			break
+		}
+		<-running.done
+		if !running.queued {
+			return running.index, false, running.err
+		}
+	}
+	defer func() {
+		fs.mu.Lock()
+		delete(fs.calls, key)
+		fs.mu.Unlock()
+		close(c.done)
+	}()
+	c.queued, c.err = f()
+	return j.index, c.queued, c.err
+}
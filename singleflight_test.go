@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fetch counts its executions in calls,
+// waiting for gate to be closed.
+type fetch struct {
+	url   string
+	calls *int32
+	gate  <-chan struct{}
+	body  string
+}
+
+func (f *fetch) Execute() {
+	atomic.AddInt32(f.calls, 1)
+	<-f.gate
+	f.body = "body of " + f.url
+}
+
+func (f *fetch) Key() string { return f.url }
+
+func (f *fetch) Result() interface{} { return f.body }
+
+func fetches(calls *int32, gate <-chan struct{}, urls ...string) []Tasker {
+	tasks := make([]Tasker, len(urls))
+	for i, u := range urls {
+		tasks[i] = &fetch{url: u, calls: calls, gate: gate}
+	}
+	return tasks
+}
+
+func TestRunCollectWith_singleFlight(t *testing.T) {
+	var calls int32
+	gate := make(chan struct{})
+	time.AfterFunc(20*time.Millisecond, func() { close(gate) })
+	tasks := fetches(&calls, gate, "a", "b", "a", "a")
+	results, err := RunCollectWith(tasks, Options{Workers: 4, SingleFlight: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected a call for every key, got %d", calls)
+	}
+	for i, r := range results {
+		if want := "body of " + tasks[i].(*fetch).url; r != want {
+			t.Fatalf("task %d: expected %q, got %q", i, want, r)
+		}
+	}
+}
+
+func TestRunWith_noSingleFlight(t *testing.T) {
+	var calls int32
+	gate := make(chan struct{})
+	close(gate)
+	if err := RunWith(fetches(&calls, gate, "a", "a", "a"), Options{Workers: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 calls, got %d", calls)
+	}
+}
+
+func TestFlights_queued(t *testing.T) {
+	fs := newFlights(Options{SingleFlight: true})
+	gate := make(chan struct{})
+	var calls int32
+	tasks := fetches(&calls, gate, "a", "a")
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		fs.do(indexedTask{0, tasks[0]}, func() (bool, error) {
+			<-gate
+			return true, nil
+		})
+	}()
+	// Wait for the leader to be in flight.
+	for {
+		fs.mu.Lock()
+		n := len(fs.calls)
+		fs.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Let the follower wait for the leader.
+	time.AfterFunc(10*time.Millisecond, func() { close(gate) })
+	var executed bool
+	leader, _, _ := fs.do(indexedTask{1, tasks[1]}, func() (bool, error) {
+		executed = true
+		return false, nil
+	})
+	<-leaderDone
+	if !executed || leader != 1 {
+		t.Fatal("expected a Tasker queued for a retry not to be shared")
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"time"
+)
+
+// Splitter is a Tasker that can be divided into smaller Taskers
+// that together do the same work.
+type Splitter interface {
+	Tasker
+	Split() []Tasker
+}
+
+// RunSplit executes jobs in parallel. A Splitter that does not
+// complete within timeout is split and its sub-Taskers are queued in
+// its place, up to maxDepth times along the same branch. Taskers that
+// are not Splitters, or are already at maxDepth, run without timeout.
+// Errors are returned as in RunSeq, sub-Taskers have the index of the
+// Tasker they have been split from.
+//
+// Go can not kill a goroutine so a timed out Tasker keeps running in
+// background after being split, what it computes must be discarded.
+// Its error, e.g. a panic, is discarded too. If timeout is not
+// positive ErrInvalidTimeout is returned.
+func RunSplit(jobs []Tasker, timeout time.Duration, maxDepth int) error {
+	return RunSplitContext(context.Background(), jobs, timeout, maxDepth, Options{})
+}
+
+// RunSplitContext is like RunSplit but uses opts as RunSeq does
+// and stops dispatching Taskers as soon as ctx is done.
+func RunSplitContext(ctx context.Context, jobs []Tasker, timeout time.Duration, maxDepth int, opts Options) error {
+	if timeout <= 0 {
+		return ErrInvalidTimeout
+	}
+	q := newDynQueue(jobs)
+	q.splitAfter, q.maxDepth = timeout, maxDepth
+	return q.run(ctx, opts)
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// interval takes 1ms per element to be evaluated.
+type interval struct {
+	start, stop int
+	mu          *sync.Mutex
+	done        map[int]bool
+}
+
+func (iv *interval) Execute() {
+	time.Sleep(time.Duration(iv.stop-iv.start) * time.Millisecond)
+	iv.mu.Lock()
+	defer iv.mu.Unlock()
+	iv.done[iv.start] = true
+}
+
+func (iv *interval) Split() []Tasker {
+	if iv.stop-iv.start <= 1 {
+		return []Tasker{iv}
+	}
+	mid := (iv.start + iv.stop) / 2
+	return []Tasker{
+		&interval{start: iv.start, stop: mid, mu: iv.mu, done: iv.done},
+		&interval{start: mid, stop: iv.stop, mu: iv.mu, done: iv.done},
+	}
+}
+
+func TestRunSplit(t *testing.T) {
+	var mu sync.Mutex
+	done := make(map[int]bool)
+	big := &interval{start: 0, stop: 64, mu: &mu, done: done}
+	if err := RunSplit([]Tasker{big}, 5*time.Millisecond, 3); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// After 3 splits the interval is made of 8 chunks of 8 elements,
+	// all but the first one exist only if big has been split.
+	for start := 8; start < 64; start += 8 {
+		if !done[start] {
+			t.Fatalf("subtask starting at %d not executed", start)
+		}
+	}
+}
+
+func TestRunSplit_taskError(t *testing.T) {
+	err := RunSplit([]Tasker{&dummy{}, panicker{}}, time.Millisecond, 1)
+	var te *TaskError
+	if !errors.As(err, &te) || te.Index != 1 {
+		t.Fatal("expected a *TaskError for task 1, got", err)
+	}
+}
+
+func TestRunSplit_invalidTimeout(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		if err := RunSplit([]Tasker{&dummy{}}, d, 1); err != ErrInvalidTimeout {
+			t.Fatalf("timeout %s: expected ErrInvalidTimeout, got %v", d, err)
+		}
+	}
+}
+
+// lateSplitter panics after being split.
+type lateSplitter struct {
+	panicked chan struct{}
+}
+
+func (s lateSplitter) Execute() {
+	time.Sleep(20 * time.Millisecond)
+	defer close(s.panicked)
+	panic("boom")
+}
+
+func (lateSplitter) Split() []Tasker { return []Tasker{&dummy{}, &dummy{}} }
+
+func TestRunSplit_abandonedPanic(t *testing.T) {
+	s := lateSplitter{make(chan struct{})}
+	if err := RunSplit([]Tasker{s}, time.Millisecond, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The panic of the abandoned Splitter is discarded.
+	<-s.panicked
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// RunStats reports how work has been distributed in a run.
+// It can be encoded as JSON for analysis with external tools,
+// durations are in nanoseconds.
+type RunStats struct {
+	// Workers has statistics of every worker. It's empty
+	// with StrategyWaitGroup which has no workers.
+	Workers []WorkerStats `json:"workers"`
+	// Tasks has statistics of every Tasker, in jobs order.
+	Tasks []TaskStats `json:"tasks"`
+	// Elapsed is the wall-clock duration of the run.
+	Elapsed time.Duration `json:"elapsed"`
+	// P50, P95 and P99 are percentiles of the durations
+	// of executed Taskers, they show the tail latency
+	// a single Elapsed hides.
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	// Throughput is the number of Taskers
+	// executed per second of Elapsed.
+	Throughput float64 `json:"throughput"`
+	// MaxQueued is the largest number of Taskers waiting for a
+	// worker in the queue, sampled as they are queued. Close to
+	// QueueDepth it means that workers were busy and a smaller queue
+	// would do, close to zero that workers were waiting for Taskers.
+	// It's zero when Taskers are executed without a queue, e.g. with
+	// a single worker.
+	MaxQueued int `json:"max_queued"`
+}
+
+// WorkerStats reports activity of a single worker.
+type WorkerStats struct {
+	// Tasks is the number of Taskers executed.
+	Tasks int `json:"tasks"`
+	// Busy is the time spent executing Taskers.
+	Busy time.Duration `json:"busy"`
+}
+
+// TaskStats reports the outcome of a single Tasker.
+type TaskStats struct {
+	// Executed is false if the run was aborted
+	// before the Tasker started.
+	Executed bool `json:"executed"`
+	// Worker is the index of the worker that executed
+	// the Tasker, -1 if none did.
+	Worker int `json:"worker"`
+	// Duration is how long the Tasker took.
+	Duration time.Duration `json:"duration"`
+	// Error is the text of the error of the Tasker,
+	// e.g. of a *PanicError, empty if it succeeded.
+	Error string `json:"error,omitempty"`
+}
+
+// RunWithStats is like RunWith but also returns statistics
+// that help to check how balanced workers were.
+func RunWithStats(jobs []Tasker, opts Options) (RunStats, error) {
+	r := runTasks(context.Background(), jobs, opts)
+	return r.stats(), r.error()
+}
+
+func (r *report) stats() RunStats {
+	s := RunStats{
+		Workers:   r.workers,
+		Elapsed:   r.elapsed,
+		Tasks:     make([]TaskStats, len(r.tasks)),
+		MaxQueued: int(r.maxQueued.Load()),
+	}
+	var durations []time.Duration
+	for i, d := range r.durations {
+		// Stats of a DryRun are its plan.
+		executed := r.executed[i] || r.planned != nil && r.planned[i]
+		t := TaskStats{Executed: executed, Worker: -1, Duration: d}
+		if executed {
+			durations = append(durations, d)
+			if len(r.workers) > 0 {
+				t.Worker = r.assigned[i]
+			}
+		}
+		if r.errs[i] != nil {
+			t.Error = r.errs[i].Error()
+		}
+		s.Tasks[i] = t
+	}
+	if len(durations) == 0 {
+		return s
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	s.P50 = percentile(durations, 50)
+	s.P95 = percentile(durations, 95)
+	s.P99 = percentile(durations, 99)
+	if r.elapsed > 0 {
+		s.Throughput = float64(len(durations)) / r.elapsed.Seconds()
+	}
+	return s
+}
+
+// percentile returns the p-th percentile of sorted
+// durations using the nearest rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
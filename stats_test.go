@@ -0,0 +1,134 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunWithStats(t *testing.T) {
+	tasks := make([]Tasker, 12)
+	for i := range tasks {
+		tasks[i] = &sleeper{d: 5 * time.Millisecond}
+	}
+	stats, err := RunWithStats(tasks, Options{Workers: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Workers) != 3 {
+		t.Fatalf("expected 3 workers stats, got %d", len(stats.Workers))
+	}
+	var total int
+	var busy time.Duration
+	for _, w := range stats.Workers {
+		total += w.Tasks
+		busy += w.Busy
+	}
+	if total != len(tasks) {
+		t.Fatalf("expected %d tasks, got %d", len(tasks), total)
+	}
+	if min := time.Duration(len(tasks)) * 5 * time.Millisecond; busy < min {
+		t.Fatalf("expected busy time of at least %s, got %s", min, busy)
+	}
+	if stats.Elapsed < 20*time.Millisecond || stats.Elapsed >= busy {
+		t.Fatalf("unexpected elapsed time %s", stats.Elapsed)
+	}
+}
+
+func TestRunWithStats_percentiles(t *testing.T) {
+	tasks := make([]Tasker, 100)
+	for i := range tasks {
+		d := time.Millisecond
+		if i == 0 {
+			d = 50 * time.Millisecond
+		}
+		tasks[i] = &sleeper{d: d}
+	}
+	stats, err := RunWithStats(tasks, Options{Workers: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.P50 < time.Millisecond || stats.P50 > stats.P95 || stats.P95 > stats.P99 {
+		t.Fatalf("unexpected percentiles %s %s %s", stats.P50, stats.P95, stats.P99)
+	}
+	if stats.P99 >= 50*time.Millisecond {
+		t.Fatalf("a single slow task should not move p99, got %s", stats.P99)
+	}
+	if stats.Throughput <= 0 {
+		t.Fatalf("expected positive throughput, got %f", stats.Throughput)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 10)
+	for i := range sorted {
+		sorted[i] = time.Duration(i + 1)
+	}
+	for _, c := range []struct {
+		p    int
+		want time.Duration
+	}{{50, 5}, {95, 10}, {99, 10}} {
+		if got := percentile(sorted, c.p); got != c.want {
+			t.Fatalf("p%d: expected %d, got %d", c.p, c.want, got)
+		}
+	}
+}
+
+func TestRunStats_json(t *testing.T) {
+	tasks := []Tasker{&dummy{}, panicker{}, &errTask{ErrTasker: &failOdd{i: 1}}, &dummy{}}
+	stats, err := RunWithStats(tasks, Options{Workers: 2})
+	if err == nil {
+		t.Fatal("expected errors of the panicking and failing tasks")
+	}
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"workers", "tasks", "elapsed", "p50", "p95", "p99", "throughput", "executed", "worker", "duration", "error", "busy"} {
+		if !strings.Contains(string(data), `"`+field+`":`) {
+			t.Fatalf("field %q missing in %s", field, data)
+		}
+	}
+	var decoded RunStats
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, stats) {
+		t.Fatalf("stats do not round-trip:\n%+v\n%+v", stats, decoded)
+	}
+	if !strings.Contains(decoded.Tasks[1].Error, "panicked") {
+		t.Fatalf("expected a panic error, got %q", decoded.Tasks[1].Error)
+	}
+	for i, ts := range decoded.Tasks {
+		if !ts.Executed || ts.Worker < 0 || ts.Worker > 1 {
+			t.Fatalf("task %d: unexpected stats %+v", i, ts)
+		}
+	}
+}
+
+func TestRunWithStats_maxQueued(t *testing.T) {
+	gate := make(chan struct{})
+	var tasks []Tasker
+	for i := 0; i < 20; i++ {
+		tasks = append(tasks, gated{&cancelable{}, gate})
+	}
+	time.AfterFunc(20*time.Millisecond, func() { close(gate) })
+	s, err := RunWithStats(tasks, Options{Workers: 2, QueueDepth: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Workers are blocked while the queue fills up.
+	if s.MaxQueued != 10 {
+		t.Fatalf("expected 10 queued tasks at most, got %d", s.MaxQueued)
+	}
+	s, _ = RunWithStats(tasks, Options{Workers: 1})
+	if s.MaxQueued != 0 {
+		t.Fatalf("expected no queue with a worker, got %d", s.MaxQueued)
+	}
+}
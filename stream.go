@@ -0,0 +1,292 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// RunNonBlocking executes Taskers received from jobs and sends
+// every completed one on the returned channel, which is closed once
+// jobs has been closed and all Taskers are done.
+// When using Run one must wait that all tasks are done and put
+// separate results together in the end, RunNonBlocking avoids that.
+// Results are sent in completion order. A panicking Tasker is
+// recovered and sent anyway.
+func RunNonBlocking(jobs <-chan Tasker) <-chan Tasker {
+	return RunNonBlockingWith(jobs, Options{})
+}
+
+// RunNonBlockingWith is like RunNonBlocking but uses opts.Workers
+// workers and buffers completed Taskers as opts.ResultBufferSize
+// and opts.Overflow say. Other options are ignored.
+func RunNonBlockingWith(jobs <-chan Tasker, opts Options) <-chan Tasker {
+	n := opts.workers()
+	results := make(chan Tasker, opts.resultBufferSize(n))
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				execute(j)
+				deliver(context.Background(), results, j, opts.Overflow)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		// Comunicate to callers that we are done.
+		close(results)
+	}()
+	return results
+}
+
+// RunSpill is like RunNonBlocking but completed Taskers wait for the
+// consumer in a buffer of at most size of them, zero or less meaning
+// the number of workers. When the buffer is full the oldest Tasker in
+// it is passed to spill, e.g. to write it to disk, rather than blocking
+// workers, so that a slow consumer does not slow down the run. spill
+// is called serially. The channel is closed once jobs has been closed
+// and all Taskers have been received or spilled.
+func RunSpill(jobs <-chan Tasker, size int, spill func(Tasker)) <-chan Tasker {
+	if size <= 0 {
+		size = workers()
+	}
+	finished := RunNonBlocking(jobs)
+	results := make(chan Tasker)
+	go func() {
+		defer close(results)
+		buf := make([]Tasker, 0, size)
+		for finished != nil || len(buf) > 0 {
+			var send chan<- Tasker
+			var next Tasker
+			if len(buf) > 0 {
+				send = results
+				next = buf[0]
+			}
+			select {
+			case t, ok := <-finished:
+				if !ok {
+					finished = nil
+					break
+				}
+				if len(buf) == size {
+					spill(buf[0])
+					buf = buf[1:]
+				}
+				buf = append(buf, t)
+			case send <- next:
+				buf = buf[1:]
+			}
+		}
+	}()
+	return results
+}
+
+// RunEncode executes Taskers received from jobs as RunNonBlocking
+// does and writes every completed one to w calling encode, so that
+// results can be streamed e.g. to a file without holding them all in
+// memory. encode is called serially from the calling goroutine.
+// After the first encode error remaining Taskers are still executed
+// but not encoded, the error is returned once jobs is closed.
+func RunEncode(jobs <-chan Tasker, w io.Writer, encode func(io.Writer, Tasker) error) error {
+	var err error
+	for t := range RunNonBlocking(jobs) {
+		if err == nil {
+			err = encode(w, t)
+		}
+	}
+	return err
+}
+
+// RunAsync starts executing jobs as Run does without blocking.
+// The returned channel receives the error of Run exactly once
+// when all jobs are done.
+func RunAsync(jobs []Tasker) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Run(jobs)
+	}()
+	return errc
+}
+
+// RunStream applies f in parallel to inputs received from inputs,
+// using workers workers (zero or less meaning the package default), and
+// sends outputs on the returned channel in completion order.
+// The channel is closed once inputs is closed and all outputs are sent.
+// When ctx is done inputs are not read anymore and outputs
+// not yet sent are dropped. A panic of f is recovered and its
+// output dropped, use RunStreamErr to receive it.
+func RunStream[In, Out any](ctx context.Context, inputs <-chan In, f func(In) Out, workers int) <-chan Out {
+	return RunStreamWith(ctx, inputs, f, workersOptions(workers))
+}
+
+// RunStreamWith is like RunStream but uses opts.Workers workers
+// and buffers outputs as opts.ResultBufferSize and opts.Overflow
+// say. Other options are ignored.
+func RunStreamWith[In, Out any](ctx context.Context, inputs <-chan In, f func(In) Out, opts Options) <-chan Out {
+	return stream(ctx, inputs, func(in In) (out Out, ok bool) {
+		defer func() {
+			if recover() != nil {
+				ok = false
+			}
+		}()
+		return f(in), true
+	}, opts)
+}
+
+// StreamResult is an output of RunStreamErr.
+type StreamResult[Out any] struct {
+	Out Out
+	// Err is the error returned by f, or a *PanicError
+	// if f panicked.
+	Err error
+}
+
+// RunStreamErr is like RunStream but f can fail, every output is
+// sent along with its error. Panics of f are sent as *PanicError.
+func RunStreamErr[In, Out any](ctx context.Context, inputs <-chan In, f func(In) (Out, error), workers int) <-chan StreamResult[Out] {
+	return stream(ctx, inputs, func(in In) (r StreamResult[Out], ok bool) {
+		ok = true
+		defer recoverPanic(&r.Err)
+		r.Out, r.Err = f(in)
+		return r, ok
+	}, workersOptions(workers))
+}
+
+// stream implements RunStream and RunStreamErr, f
+// reports whether its output must be sent.
+func stream[In, Out any](ctx context.Context, inputs <-chan In, f func(In) (Out, bool), opts Options) <-chan Out {
+	n := opts.workers()
+	outputs := make(chan Out, opts.resultBufferSize(n))
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				var in In
+				var ok bool
+				select {
+				case in, ok = <-inputs:
+					if !ok {
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+				out, send := f(in)
+				if !send {
+					continue
+				}
+				if !deliver(ctx, outputs, out, opts.Overflow) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(outputs)
+	}()
+	return outputs
+}
+
+// deliver sends v on out as policy says, it reports
+// false if ctx is done while blocking.
+func deliver[T any](ctx context.Context, out chan T, v T, policy OverflowPolicy) bool {
+	switch policy {
+	case OverflowDropNewest:
+		select {
+		case out <- v:
+		default:
+		}
+		return true
+	case OverflowDropOldest:
+		for {
+			select {
+			case out <- v:
+				return true
+			default:
+			}
+			// The consumer may take it first.
+			select {
+			case <-out:
+			default:
+			}
+		}
+	}
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Completed is a Tasker along with its submission index.
+type Completed struct {
+	Index int
+	Task  Tasker
+}
+
+// RunOrdered is like RunNonBlocking but completed Taskers are sent in
+// submission order, tagged with their index. Taskers completed ahead
+// of time are buffered. To bound memory at most window Taskers are
+// running or buffered at any time: a slow Tasker stops reading from
+// jobs until it completes. window zero or less means the number of
+// workers.
+func RunOrdered(jobs <-chan Tasker, window int) <-chan Completed {
+	n := workers()
+	if window <= 0 {
+		window = n
+	}
+	slots := make(chan struct{}, window)
+	queue := make(chan Completed)
+	finished := make(chan Completed, n)
+	results := make(chan Completed, n)
+	go func() {
+		var i int
+		for j := range jobs {
+			slots <- struct{}{}
+			queue <- Completed{i, j}
+			i++
+		}
+		close(queue)
+	}()
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for c := range queue {
+				execute(c.Task)
+				finished <- c
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	go func() {
+		pending := make(map[int]Tasker, window)
+		var next int
+		for c := range finished {
+			pending[c.Index] = c.Task
+			for t, ok := pending[next]; ok; t, ok = pending[next] {
+				results <- Completed{next, t}
+				delete(pending, next)
+				next++
+				<-slots
+			}
+		}
+		close(results)
+	}()
+	return results
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunNonBlocking(t *testing.T) {
+	jobs := make(chan Tasker)
+	sent := make(map[Tasker]bool)
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = Tasker(&dummy{})
+		sent[tasks[i]] = true
+	}
+	go func() {
+		for _, t := range tasks {
+			jobs <- t
+		}
+		close(jobs)
+	}()
+	var received int
+	for r := range RunNonBlocking(jobs) {
+		if !sent[r] {
+			t.Fatal("received unknown task")
+		}
+		if !r.(*dummy).done {
+			t.Fatal("received task not executed")
+		}
+		delete(sent, r)
+		received++
+	}
+	if received != len(tasks) {
+		t.Fatalf("expected %d results, got %d", len(tasks), received)
+	}
+}
+
+func TestRunStream(t *testing.T) {
+	inputs := make(chan uint64)
+	go func() {
+		for i := uint64(0); i < 1e3; i++ {
+			inputs <- i
+		}
+		close(inputs)
+	}()
+	var primes int
+	for p := range RunStream(context.Background(), inputs, isPrime, 0) {
+		if p {
+			primes++
+		}
+	}
+	// 0, 1 and 2 are considered primes by isPrime.
+	if primes != 170 {
+		t.Fatalf("expected 170 primes, got %d", primes)
+	}
+}
+
+func TestRunStream_cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	// Never closed.
+	inputs := make(chan int)
+	outputs := RunStream(ctx, inputs, func(i int) int { return i }, 2)
+	inputs <- 1
+	if o := <-outputs; o != 1 {
+		t.Fatalf("expected 1, got %d", o)
+	}
+	cancel()
+	for range outputs {
+	}
+}
+
+// panicOn3 panics on input 3.
+func panicOn3(i int) int {
+	if i == 3 {
+		panic("boom")
+	}
+	return i
+}
+
+func TestRunStream_panic(t *testing.T) {
+	inputs := make(chan int)
+	go func() {
+		for i := 0; i < 10; i++ {
+			inputs <- i
+		}
+		close(inputs)
+	}()
+	var received int
+	for o := range RunStream(context.Background(), inputs, panicOn3, 2) {
+		if o == 3 {
+			t.Fatal("output of a panicking f has been sent")
+		}
+		received++
+	}
+	if received != 9 {
+		t.Fatalf("expected 9 outputs, got %d", received)
+	}
+}
+
+func TestRunStreamErr(t *testing.T) {
+	inputs := make(chan int)
+	go func() {
+		for i := 0; i < 10; i++ {
+			inputs <- i
+		}
+		close(inputs)
+	}()
+	f := func(i int) (int, error) {
+		if i == 5 {
+			return 0, errOdd
+		}
+		return panicOn3(i), nil
+	}
+	var failed, panicked int
+	for r := range RunStreamErr(context.Background(), inputs, f, 2) {
+		var pe *PanicError
+		switch {
+		case errors.As(r.Err, &pe):
+			panicked++
+		case errors.Is(r.Err, errOdd):
+			failed++
+		case r.Err != nil:
+			t.Fatal("unexpected error", r.Err)
+		}
+	}
+	if failed != 1 || panicked != 1 {
+		t.Fatalf("expected 1 failure and 1 panic, got %d and %d", failed, panicked)
+	}
+}
+
+func TestRunSpill(t *testing.T) {
+	jobs := make(chan Tasker)
+	tasks := make([]Tasker, 1e2)
+	for i := range tasks {
+		tasks[i] = &dummy{}
+	}
+	go func() {
+		for _, t := range tasks {
+			jobs <- t
+		}
+		close(jobs)
+	}()
+	var spilled int32
+	results := RunSpill(jobs, 5, func(Tasker) { atomic.AddInt32(&spilled, 1) })
+	// Nothing is received until workers are done.
+	for i := 0; atomic.LoadInt32(&spilled) != 95; i++ {
+		if i == 100 {
+			t.Fatalf("workers blocked, %d tasks spilled", atomic.LoadInt32(&spilled))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var received int
+	for range results {
+		received++
+	}
+	if received != 5 {
+		t.Fatalf("expected 5 buffered results, got %d", received)
+	}
+}
+
+func TestRunOrdered(t *testing.T) {
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			jobs := make(chan Tasker)
+			tasks := make([]Tasker, 50)
+			for i := range tasks {
+				// Early Taskers are slower.
+				tasks[i] = &sleeper{d: time.Duration(len(tasks)-i) * 100 * time.Microsecond}
+			}
+			go func() {
+				for _, t := range tasks {
+					jobs <- t
+				}
+				close(jobs)
+			}()
+			var next int
+			for c := range RunOrdered(jobs, n) {
+				if c.Index != next {
+					t.Fatalf("expected index %d, got %d", next, c.Index)
+				}
+				if c.Task != tasks[c.Index] {
+					t.Fatalf("wrong task at index %d", c.Index)
+				}
+				next++
+			}
+			if next != len(tasks) {
+				t.Fatalf("expected %d results, got %d", len(tasks), next)
+			}
+		})
+	}
+}
+
+func TestRunAsync(t *testing.T) {
+	initTests()
+	errc := RunAsync(testCases)
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range testCases {
+		if !e.(*dummy).done {
+			t.Fatal("task not executed")
+		}
+	}
+	errc = RunAsync([]Tasker{panicker{}})
+	if err := <-errc; err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestRunEncode(t *testing.T) {
+	jobs := make(chan Tasker)
+	go func() {
+		for i := 0; i < 1e2; i++ {
+			jobs <- &square{n: i}
+		}
+		close(jobs)
+	}()
+	var b bytes.Buffer
+	err := RunEncode(jobs, &b, func(w io.Writer, t Tasker) error {
+		_, err := fmt.Fprintln(w, t.(*square).result)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	squares := make(map[int]bool)
+	s := bufio.NewScanner(&b)
+	for s.Scan() {
+		n, err := strconv.Atoi(s.Text())
+		if err != nil {
+			t.Fatal(err)
+		}
+		squares[n] = true
+	}
+	for i := 0; i < 1e2; i++ {
+		if !squares[i*i] {
+			t.Fatalf("missing square of %d", i)
+		}
+	}
+}
+
+func TestRunEncode_error(t *testing.T) {
+	jobs := make(chan Tasker, 10)
+	tasks := make([]*dummy, 10)
+	for i := range tasks {
+		tasks[i] = &dummy{}
+		jobs <- tasks[i]
+	}
+	close(jobs)
+	errEncode := errors.New("encode failed")
+	var calls int
+	err := RunEncode(jobs, io.Discard, func(io.Writer, Tasker) error {
+		calls++
+		return errEncode
+	})
+	if err != errEncode {
+		t.Fatal("expected errEncode, got", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected encode to be called once, got %d", calls)
+	}
+	for _, e := range tasks {
+		if !e.done {
+			t.Fatal("task not executed")
+		}
+	}
+}
+
+// numbered is a Tasker with its submission index.
+type numbered int
+
+func (numbered) Execute() {}
+
+func TestRunNonBlockingWith_overflow(t *testing.T) {
+	policies := map[OverflowPolicy][]int{
+		OverflowBlock:      {0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		OverflowDropNewest: {0, 1, 2},
+		OverflowDropOldest: {7, 8, 9},
+	}
+	for policy, want := range policies {
+		jobs := make(chan Tasker, 10)
+		for i := 0; i < 10; i++ {
+			jobs <- numbered(i)
+		}
+		close(jobs)
+		results := RunNonBlockingWith(jobs, Options{Workers: 1, ResultBufferSize: 3, Overflow: policy})
+		if policy != OverflowBlock {
+			// Let the run complete without reading.
+			for len(jobs) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		var got []int
+		for r := range results {
+			got = append(got, int(r.(numbered)))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("policy %d: expected %v, got %v", policy, want, got)
+		}
+	}
+}
+
+func TestRunStreamWith_dropNewest(t *testing.T) {
+	inputs := make(chan int, 100)
+	for i := 0; i < 100; i++ {
+		inputs <- i
+	}
+	close(inputs)
+	var done int32
+	outputs := RunStreamWith(context.Background(), inputs, func(i int) int {
+		atomic.AddInt32(&done, 1)
+		return i
+	}, Options{Workers: 4, ResultBufferSize: 5, Overflow: OverflowDropNewest})
+	for atomic.LoadInt32(&done) < 100 {
+		time.Sleep(time.Millisecond)
+	}
+	var received int
+	for range outputs {
+		received++
+	}
+	if received < 1 || received > 5 {
+		t.Fatalf("expected at most 5 buffered outputs, got %d", received)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"sync"
+	"time"
+)
+
+// watchdog logs Taskers running for longer than
+// Options.WarnAfter, once each.
+type watchdog struct {
+	after time.Duration
+	l     Logger
+	mu    sync.Mutex
+	// running has the Tasker of every worker.
+	running []watched
+	quit    chan struct{}
+}
+
+// watched is a Tasker being executed by a worker.
+type watched struct {
+	index  int
+	start  time.Time
+	busy   bool
+	warned bool
+}
+
+// newWatchdog starts a watchdog of n workers, nil
+// if opts.WarnAfter or opts.Logger are not set.
+func newWatchdog(opts Options, n int) *watchdog {
+	if opts.WarnAfter == 0 || opts.Logger == nil {
+		return nil
+	}
+	w := &watchdog{
+		after:   opts.WarnAfter,
+		l:       opts.Logger,
+		running: make([]watched, n),
+		quit:    make(chan struct{}),
+	}
+	go w.watch()
+	return w
+}
+
+// watch checks running Taskers twice every after
+// until stop is called.
+func (w *watchdog) watch() {
+	ticker := time.NewTicker(w.after / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+func (w *watchdog) check() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id := range w.running {
+		r := &w.running[id]
+		if !r.busy || r.warned {
+			continue
+		}
+		if running := time.Since(r.start); running >= w.after {
+			logEvent(w.l, "parallel: task running long", "task", r.index, "worker", id, "running", running)
+			r.warned = true
+		}
+	}
+}
+
+// started records that worker id started the index-th Tasker.
+func (w *watchdog) started(id, index int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[id] = watched{index: index, start: time.Now(), busy: true}
+}
+
+// done records that worker id completed its Tasker.
+func (w *watchdog) done(id int) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running[id].busy = false
+}
+
+// stop stops w.
+func (w *watchdog) stop() {
+	if w != nil {
+		close(w.quit)
+	}
+}
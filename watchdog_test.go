@@ -0,0 +1,56 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// warnLogger keeps the arguments of task running long events.
+type warnLogger struct {
+	mu       sync.Mutex
+	warnings []map[string]any
+}
+
+func (l *warnLogger) Info(msg string, args ...any) {
+	if msg != "parallel: task running long" {
+		return
+	}
+	w := make(map[string]any)
+	for i := 0; i < len(args); i += 2 {
+		w[args[i].(string)] = args[i+1]
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, w)
+}
+
+func TestRunWith_warnAfter(t *testing.T) {
+	for _, n := range testWorkers {
+		t.Run(fmt.Sprintf("workers=%d", n), func(t *testing.T) {
+			l := &warnLogger{}
+			jobs := []Tasker{&dummy{}, &sleeper{d: 50 * time.Millisecond}, &dummy{}}
+			opts := Options{Workers: n, WarnAfter: 10 * time.Millisecond, Logger: l}
+			if err := RunWith(jobs, opts); err != nil {
+				t.Fatal(err)
+			}
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			warnings := l.warnings
+			if len(warnings) != 1 {
+				t.Fatalf("expected a warning, got %d", len(warnings))
+			}
+			if task := warnings[0]["task"]; task != 1 {
+				t.Fatal("expected a warning for task 1, got", task)
+			}
+			if running := warnings[0]["running"].(time.Duration); running < opts.WarnAfter {
+				t.Fatal("warned too early, after", running)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"context"
+	"time"
+)
+
+// YieldingTasker models a long task that cooperates with others
+// of its run. It calls yield from time to time: once yield returns
+// false Execute must save its progress and return, it's executed
+// again, resuming from there, after Taskers waiting in the queue.
+// yield returns false also once the run is aborted, then the Tasker
+// is not executed again.
+type YieldingTasker interface {
+	Execute(yield func() bool)
+}
+
+// RunYielding is like Run but executes YieldingTaskers.
+func RunYielding(jobs []YieldingTasker) error {
+	return RunYieldingWith(jobs, Options{})
+}
+
+// RunYieldingWith is like RunYielding but uses opts for this run only.
+// With a single worker Taskers are never rescheduled.
+func RunYieldingWith(jobs []YieldingTasker, opts Options) error {
+	tasks := make([]Tasker, len(jobs))
+	for i, j := range jobs {
+		if j != nil {
+			tasks[i] = &yieldTask{t: j}
+		}
+	}
+	return RunWith(tasks, opts)
+}
+
+// yieldTask adapts a YieldingTasker to Tasker, workers
+// set waiting before executing it.
+type yieldTask struct {
+	t YieldingTasker
+	// waiting reports whether other Taskers
+	// wait for the worker, nil if none can.
+	waiting func() bool
+	// yielded tells the last execution returned
+	// to let waiting Taskers run.
+	yielded bool
+	// ran is the time spent in previous executions.
+	ran time.Duration
+}
+
+func (y *yieldTask) Execute() {
+	y.executeCtx(context.Background())
+}
+
+func (y *yieldTask) executeCtx(ctx context.Context) error {
+	y.yielded = false
+	y.t.Execute(func() bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		if y.waiting != nil && y.waiting() {
+			y.yielded = true
+			return false
+		}
+		return true
+	})
+	return nil
+}
+
+// resumable returns j as a *yieldTask if it yielded
+// while executed by a worker and the run goes on.
+func resumable(ctx context.Context, j indexedTask, err error) (*yieldTask, bool) {
+	y, ok := j.Tasker.(*yieldTask)
+	if !ok || err != nil || ctx.Err() != nil || !y.yielded {
+		return nil, false
+	}
+	return y, true
+}
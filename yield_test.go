@@ -0,0 +1,95 @@
+// Copyright (c) 2015 Andrea Masi. All rights reserved.
+// Use of this source code is governed by a MIT license
+// that can be found in the LICENSE.txt file.
+
+package parallel
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// looper takes steps, yielding after each one.
+type looper struct {
+	steps, runs int
+}
+
+func (l *looper) Execute(yield func() bool) {
+	l.runs++
+	for l.steps < 100 {
+		l.steps++
+		time.Sleep(100 * time.Microsecond)
+		if !yield() {
+			return
+		}
+	}
+}
+
+// oneShot sleeps for a millisecond, it never yields.
+type oneShot struct {
+	n int32
+}
+
+func (o *oneShot) Execute(func() bool) {
+	atomic.AddInt32(&o.n, 1)
+	time.Sleep(time.Millisecond)
+}
+
+func TestRunYieldingWith(t *testing.T) {
+	l := &looper{}
+	jobs := []YieldingTasker{l}
+	shots := make([]*oneShot, 50)
+	for i := range shots {
+		shots[i] = &oneShot{}
+		jobs = append(jobs, shots[i])
+	}
+	if err := RunYieldingWith(jobs, Options{Workers: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if l.steps != 100 {
+		t.Fatalf("expected 100 steps, got %d", l.steps)
+	}
+	if l.runs < 2 {
+		t.Fatal("expected the task to yield to waiting ones")
+	}
+	for i, o := range shots {
+		if o.n != 1 {
+			t.Fatalf("task %d executed %d times", i, o.n)
+		}
+	}
+}
+
+func TestRunYieldingWith_singleWorker(t *testing.T) {
+	l := &looper{}
+	if err := RunYieldingWith([]YieldingTasker{l, &oneShot{}}, Options{Workers: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if l.runs != 1 || l.steps != 100 {
+		t.Fatalf("expected 100 steps in a run, got %d in %d", l.steps, l.runs)
+	}
+}
+
+// spinner yields until the run is aborted.
+type spinner struct {
+	runs int32
+}
+
+func (e *spinner) Execute(yield func() bool) {
+	atomic.AddInt32(&e.runs, 1)
+	for yield() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunYieldingWith_timeout(t *testing.T) {
+	e := &spinner{}
+	err := RunYieldingWith([]YieldingTasker{e}, Options{Workers: 2, Timeout: 20 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatal("expected ErrTimeout, got", err)
+	}
+	if n := atomic.LoadInt32(&e.runs); n != 1 {
+		t.Fatalf("expected a single execution, got %d", n)
+	}
+}